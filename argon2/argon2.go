@@ -3,25 +3,71 @@ package argon2
 import (
 	"crypto/subtle"
 	"encoding/base64"
-	"errors"
 	"fmt"
 	"strings"
 
+	"github.com/alexandrevicenzi/unchained/internal/entropy"
+	"github.com/alexandrevicenzi/unchained/internal/hasherr"
 	"golang.org/x/crypto/argon2"
 )
 
 // Errors returned by Argon2Hasher.
+//
+// Errors about a component of an encoded password are wrapped
+// in an unchained.HashError, match them with errors.Is.
 var (
-	ErrHashComponentUnreadable = errors.New("unchained/argon2: unreadable component in hashed password")
-	ErrHashComponentMismatch   = errors.New("unchained/argon2: hashed password components mismatch")
-	ErrAlgorithmMismatch       = errors.New("unchained/argon2: algorithm mismatch")
-	ErrIncompatibleVersion     = errors.New("unchained/argon2: incompatible version")
+	ErrHashComponentUnreadable = hasherr.New(hasherr.ErrMalformedHash, "unchained/argon2: unreadable component in hashed password")
+	ErrHashComponentMismatch   = hasherr.New(hasherr.ErrMalformedHash, "unchained/argon2: hashed password components mismatch")
+	ErrAlgorithmMismatch       = hasherr.New(hasherr.ErrUnsupportedAlgorithm, "unchained/argon2: algorithm mismatch")
+	ErrIncompatibleVersion     = hasherr.New(hasherr.ErrUnsupportedVersion, "unchained/argon2: incompatible version")
+	ErrLimitExceeded           = hasherr.New(hasherr.ErrLimitExceeded, "unchained/argon2: parameters exceed limits")
+	ErrInvalidParameters       = hasherr.New(hasherr.ErrInvalidParameters, "unchained/argon2: invalid parameters")
+	ErrPasswordTooLong         = hasherr.New(hasherr.ErrPasswordTooLong, "unchained/argon2: password too long")
 )
 
-// Argon2Hasher implements Argon2i password hasher.
+// Minimum length of the hash in bytes, as in the reference implementation.
+const minLength = 4
+
+// Limits used by Verify when the corresponding field of Argon2Hasher is zero.
+const (
+	DefaultMaxMemory  = 1024 * 1024
+	DefaultMaxTime    = 64
+	DefaultMaxThreads = 64
+	DefaultMaxLength  = 1024
+)
+
+// DecodedHash holds the components of an Argon2 encoded password.
+type DecodedHash struct {
+	// Algorithm identifier.
+	Algorithm string
+	// Argon2 variant, e.g. argon2id.
+	Variant string
+	// Argon2 version.
+	Version int
+	// Memory usage (KiB).
+	Memory uint32
+	// Number of iterations.
+	Time uint32
+	// Number of parallel threads.
+	Threads uint8
+	// Salt used to encode the password.
+	Salt string
+	// Base64 encoded hash.
+	Hash string
+}
+
+// Argon2 variants.
+const (
+	Argon2i  = "argon2i"
+	Argon2id = "argon2id"
+)
+
+// Argon2Hasher implements Argon2i and Argon2id password hasher.
 type Argon2Hasher struct {
 	// Algorithm identifier.
 	Algorithm string
+	// Defines the Argon2 variant used to encode passwords, Argon2i or Argon2id.
+	Variant string
 	// Defines the amount of computation time, given in number of iterations.
 	Time uint32
 	// Defines the memory usage (KiB).
@@ -30,19 +76,101 @@ type Argon2Hasher struct {
 	Threads uint8
 	// Defines the length of the hash in bytes.
 	Length uint32
+	// Defines the maximum memory usage (KiB) accepted by Verify,
+	// zero means DefaultMaxMemory.
+	MaxMemory uint32
+	// Defines the maximum number of iterations accepted by Verify,
+	// zero means DefaultMaxTime.
+	MaxTime uint32
+	// Defines the maximum number of threads accepted by Verify,
+	// zero means DefaultMaxThreads.
+	MaxThreads uint8
+	// Defines the maximum length of the hash in bytes accepted by Verify,
+	// zero means DefaultMaxLength.
+	MaxLength uint32
+	// Defines the maximum length of passwords in bytes, zero means no limit.
+	MaxPasswordLength int
+	// Defines the minimum entropy of salts in bits, zero means unchained.DefaultSaltEntropy.
+	// Encoded digests whose salt has less entropy must be updated.
+	SaltEntropy int
+}
+
+// limit returns max, or def if max is zero.
+func limit(max, def uint32) uint32 {
+	if max == 0 {
+		return def
+	}
+
+	return max
+}
+
+// checkParams returns an error if the parameters would make
+// "golang.org/x/crypto/argon2" panic or produce a meaningless hash.
+func (h *Argon2Hasher) checkParams(time uint32, threads uint8, length uint32) error {
+	switch {
+	case time < 1:
+		return hasherr.Wrap(h.Algorithm, "time_cost", ErrInvalidParameters)
+	case threads < 1:
+		return hasherr.Wrap(h.Algorithm, "parallelism", ErrInvalidParameters)
+	case length < minLength:
+		return hasherr.Wrap(h.Algorithm, "hash", ErrInvalidParameters)
+	}
+
+	return nil
+}
+
+// checkLimits returns an error if the decoded parameters
+// or the hash length exceed the limits of the hasher.
+func (h *Argon2Hasher) checkLimits(d *DecodedHash, length int) error {
+	switch {
+	case d.Memory > limit(h.MaxMemory, DefaultMaxMemory):
+		return hasherr.Wrap(h.Algorithm, "memory_cost", ErrLimitExceeded)
+	case d.Time > limit(h.MaxTime, DefaultMaxTime):
+		return hasherr.Wrap(h.Algorithm, "time_cost", ErrLimitExceeded)
+	case uint32(d.Threads) > limit(uint32(h.MaxThreads), DefaultMaxThreads):
+		return hasherr.Wrap(h.Algorithm, "parallelism", ErrLimitExceeded)
+	case uint64(length) > uint64(limit(h.MaxLength, DefaultMaxLength)):
+		return hasherr.Wrap(h.Algorithm, "hash", ErrLimitExceeded)
+	}
+
+	return nil
+}
+
+// key derives the hash using the given Argon2 variant.
+func key(variant string, password, salt []byte, time, memory uint32, threads uint8, length uint32) ([]byte, error) {
+	switch variant {
+	case Argon2i:
+		return argon2.Key(password, salt, time, memory, threads, length), nil
+	case Argon2id:
+		return argon2.IDKey(password, salt, time, memory, threads, length), nil
+	}
+
+	return nil, ErrAlgorithmMismatch
 }
 
 // Encode turns a plain-text password into a hash.
 func (h *Argon2Hasher) Encode(password string, salt string) (string, error) {
+	if h.MaxPasswordLength > 0 && len(password) > h.MaxPasswordLength {
+		return "", ErrPasswordTooLong
+	}
+
+	if err := h.checkParams(h.Time, h.Threads, h.Length); err != nil {
+		return "", err
+	}
+
 	bSalt := []byte(salt)
-	hash := argon2.Key([]byte(password), bSalt, h.Time, h.Memory, h.Threads, h.Length)
+	hash, err := key(h.Variant, []byte(password), bSalt, h.Time, h.Memory, h.Threads, h.Length)
+
+	if err != nil {
+		return "", hasherr.Wrap(h.Algorithm, "variant", err)
+	}
 
 	b64Salt := base64.RawStdEncoding.EncodeToString(bSalt)
 	b64Hash := base64.RawStdEncoding.EncodeToString(hash)
 
 	s := fmt.Sprintf("%s$%s$v=%d$m=%d,t=%d,p=%d$%s$%s",
 		h.Algorithm,
-		"argon2i",
+		h.Variant,
 		argon2.Version,
 		h.Memory,
 		h.Time,
@@ -54,66 +182,147 @@ func (h *Argon2Hasher) Encode(password string, salt string) (string, error) {
 	return s, nil
 }
 
-// Verify if a plain-text password matches the encoded digest.
-func (h *Argon2Hasher) Verify(password string, encoded string) (bool, error) {
+// Decode splits the encoded digest into its components.
+func (h *Argon2Hasher) Decode(encoded string) (*DecodedHash, error) {
 	s := strings.Split(encoded, "$")
 
 	if len(s) != 6 {
-		return false, ErrHashComponentMismatch
+		return nil, ErrHashComponentMismatch
 	}
 
-	algorithm, method, version, params, salt, hash := s[0], s[1], s[2], s[3], s[4], s[5]
+	algorithm, variant, version, params, salt, hash := s[0], s[1], s[2], s[3], s[4], s[5]
 
-	if algorithm != h.Algorithm || method != "argon2i" {
-		return false, ErrAlgorithmMismatch
+	if algorithm != h.Algorithm {
+		return nil, hasherr.Wrap(h.Algorithm, "algorithm", ErrAlgorithmMismatch)
 	}
 
-	var v int
-	var err error
+	d := &DecodedHash{
+		Algorithm: algorithm,
+		Variant:   variant,
+		Hash:      hash,
+	}
 
-	_, err = fmt.Sscanf(version, "v=%d", &v)
+	_, err := fmt.Sscanf(version, "v=%d", &d.Version)
 
 	if err != nil {
-		return false, ErrHashComponentUnreadable
+		return nil, hasherr.Wrap(h.Algorithm, "version", ErrHashComponentUnreadable)
 	}
 
-	if v != argon2.Version {
-		return false, ErrIncompatibleVersion
-	}
+	_, err = fmt.Sscanf(params, "m=%d,t=%d,p=%d", &d.Memory, &d.Time, &d.Threads)
 
-	var time, memory uint32
-	var threads uint8
+	if err != nil {
+		return nil, hasherr.Wrap(h.Algorithm, "parameters", ErrHashComponentUnreadable)
+	}
 
-	_, err = fmt.Sscanf(params, "m=%d,t=%d,p=%d", &memory, &time, &threads)
+	bSalt, err := base64.RawStdEncoding.DecodeString(salt)
 
 	if err != nil {
-		return false, ErrHashComponentUnreadable
+		return nil, hasherr.Wrap(h.Algorithm, "salt", ErrHashComponentUnreadable)
 	}
 
-	bSalt, err := base64.RawStdEncoding.DecodeString(salt)
+	d.Salt = string(bSalt)
+
+	return d, nil
+}
+
+// Verify if a plain-text password matches the encoded digest.
+func (h *Argon2Hasher) Verify(password string, encoded string) (bool, error) {
+	if h.MaxPasswordLength > 0 && len(password) > h.MaxPasswordLength {
+		return false, ErrPasswordTooLong
+	}
+
+	d, err := h.Decode(encoded)
 
 	if err != nil {
-		return false, ErrHashComponentUnreadable
+		return false, err
+	}
+
+	if d.Version != argon2.Version {
+		return false, hasherr.Wrap(h.Algorithm, "version", ErrIncompatibleVersion)
 	}
 
-	bHash, err := base64.RawStdEncoding.DecodeString(hash)
+	bHash, err := base64.RawStdEncoding.DecodeString(d.Hash)
 
 	if err != nil {
-		return false, ErrHashComponentUnreadable
+		return false, hasherr.Wrap(h.Algorithm, "hash", ErrHashComponentUnreadable)
+	}
+
+	if err := h.checkParams(d.Time, d.Threads, uint32(len(bHash))); err != nil {
+		return false, err
 	}
 
-	newHash := argon2.Key([]byte(password), bSalt, time, memory, threads, uint32(len(bHash)))
+	if err := h.checkLimits(d, len(bHash)); err != nil {
+		return false, err
+	}
+
+	newHash, err := key(d.Variant, []byte(password), []byte(d.Salt), d.Time, d.Memory, d.Threads, uint32(len(bHash)))
+
+	if err != nil {
+		return false, hasherr.Wrap(h.Algorithm, "variant", err)
+	}
 
 	return subtle.ConstantTimeCompare(bHash, newHash) == 1, nil
 }
 
+// MustUpdate returns true if the encoded digest uses different
+// variant, version, time, memory, threads or hash length than the hasher,
+// or a salt with less entropy than SaltEntropy.
+func (h *Argon2Hasher) MustUpdate(encoded string) bool {
+	d, err := h.Decode(encoded)
+
+	if err != nil {
+		return false
+	}
+
+	length := uint32(base64.RawStdEncoding.DecodedLen(len(d.Hash)))
+
+	return d.Variant != h.Variant ||
+		d.Version != argon2.Version ||
+		d.Time != h.Time ||
+		d.Memory != h.Memory ||
+		d.Threads != h.Threads ||
+		length != h.Length ||
+		!entropy.Enough(d.Salt, h.SaltEntropy)
+}
+
+// HardenRuntime runs the work missing from the encoded digest to match
+// the time and memory of the hasher, so verification takes about the
+// same time regardless of the parameters used to encode the password.
+func (h *Argon2Hasher) HardenRuntime(password string, encoded string) error {
+	d, err := h.Decode(encoded)
+
+	if err != nil {
+		return err
+	}
+
+	if err := h.checkParams(h.Time, h.Threads, h.Length); err != nil {
+		return err
+	}
+
+	current := uint64(d.Time) * uint64(d.Memory)
+	wanted := uint64(h.Time) * uint64(h.Memory)
+
+	if current >= wanted || h.Memory == 0 {
+		return nil
+	}
+
+	time := uint32((wanted - current + uint64(h.Memory) - 1) / uint64(h.Memory))
+	_, err = key(h.Variant, []byte(password), []byte(d.Salt), time, h.Memory, h.Threads, h.Length)
+
+	return err
+}
+
 // NewArgon2Hasher secures password hashing using the argon2 algorithm.
+//
+// Configured to use Argon2id with the same parameters as Django.
 func NewArgon2Hasher() *Argon2Hasher {
 	return &Argon2Hasher{
-		Algorithm: "argon2",
-		Time:      2,
-		Memory:    512,
-		Threads:   2,
-		Length:    16,
+		Algorithm:   "argon2",
+		Variant:     Argon2id,
+		Time:        2,
+		Memory:      102400,
+		Threads:     8,
+		Length:      32,
+		SaltEntropy: entropy.Default,
 	}
 }
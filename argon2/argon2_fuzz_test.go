@@ -0,0 +1,26 @@
+//go:build go1.18
+// +build go1.18
+
+package argon2
+
+import "testing"
+
+func FuzzArgon2Verify(f *testing.F) {
+	f.Add("argon2$argon2id$v=19$m=512,t=2,p=2$NnFZNGxmQTE1bmFV$/noD0KSu5OEnoJHi1E4KFA")
+	f.Add("argon2$argon2i$v=19$m=512,t=2,p=2$MEE1bXNlQmxKQ2R4$hQG9ASj+0Hg8+ZDMzoS5Dg")
+	f.Add("argon2$argon2id$v=19$m=8,t=0,p=0$c2FsdA$")
+
+	f.Fuzz(func(t *testing.T, encoded string) {
+		h := NewArgon2Hasher()
+		h.MaxMemory = 64
+		h.MaxTime = 2
+		h.MaxThreads = 2
+
+		if _, err := h.Decode(encoded); err != nil {
+			return
+		}
+
+		h.Verify("admin", encoded)
+		h.MustUpdate(encoded)
+	})
+}
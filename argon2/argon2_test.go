@@ -1,11 +1,25 @@
 package argon2
 
 import (
+	"errors"
+	"strings"
 	"testing"
 )
 
+// newArgon2iHasher returns the hasher with the parameters used by Django before Argon2id.
+func newArgon2iHasher() *Argon2Hasher {
+	return &Argon2Hasher{
+		Algorithm: "argon2",
+		Variant:   Argon2i,
+		Time:      2,
+		Memory:    512,
+		Threads:   2,
+		Length:    16,
+	}
+}
+
 func TestArgon2Encode1(t *testing.T) {
-	encoded, err := NewArgon2Hasher().Encode("admin", "6qY4lfA15naU")
+	encoded, err := newArgon2iHasher().Encode("admin", "6qY4lfA15naU")
 
 	if err != nil {
 		t.Fatalf("Encode error: %s", err)
@@ -19,7 +33,7 @@ func TestArgon2Encode1(t *testing.T) {
 }
 
 func TestArgon2Encode2(t *testing.T) {
-	encoded, err := NewArgon2Hasher().Encode("this-is-my-password", "h8lI73ohfXug")
+	encoded, err := newArgon2iHasher().Encode("this-is-my-password", "h8lI73ohfXug")
 
 	if err != nil {
 		t.Fatalf("Encode error: %s", err)
@@ -33,7 +47,7 @@ func TestArgon2Encode2(t *testing.T) {
 }
 
 func TestArgon2Encode3(t *testing.T) {
-	encoded, err := NewArgon2Hasher().Encode("Th1S1sMYp4ssw0rd", "HUxfcH4lx2SP")
+	encoded, err := newArgon2iHasher().Encode("Th1S1sMYp4ssw0rd", "HUxfcH4lx2SP")
 
 	if err != nil {
 		t.Fatalf("Encode error: %s", err)
@@ -47,7 +61,7 @@ func TestArgon2Encode3(t *testing.T) {
 }
 
 func TestArgon2Encode4(t *testing.T) {
-	encoded, err := NewArgon2Hasher().Encode("this$is#my@PASSWORD", "0iHb4EQbyJzL")
+	encoded, err := newArgon2iHasher().Encode("this$is#my@PASSWORD", "0iHb4EQbyJzL")
 
 	if err != nil {
 		t.Fatalf("Encode error: %s", err)
@@ -119,3 +133,125 @@ func TestArgon2VerifyInvalidPassword(t *testing.T) {
 		t.Fatal("Password should not be valid.")
 	}
 }
+
+func TestArgon2Decode(t *testing.T) {
+	d, err := NewArgon2Hasher().Decode("argon2$argon2i$v=19$m=512,t=2,p=2$NnFZNGxmQTE1bmFV$kPPGrqD6dnRllcQeksFN+w")
+
+	if err != nil {
+		t.Fatalf("Decode error: %s", err)
+	}
+
+	if d.Variant != "argon2i" || d.Version != 19 || d.Memory != 512 || d.Time != 2 || d.Threads != 2 || d.Salt != "6qY4lfA15naU" || d.Hash != "kPPGrqD6dnRllcQeksFN+w" {
+		t.Fatalf("Decoded hash %+v does not match.", d)
+	}
+}
+
+func TestArgon2MustUpdate(t *testing.T) {
+	h := newArgon2iHasher()
+
+	if h.MustUpdate("argon2$argon2i$v=19$m=512,t=2,p=2$NnFZNGxmQTE1bmFV$kPPGrqD6dnRllcQeksFN+w") {
+		t.Fatal("Hash with same parameters should not be updated.")
+	}
+
+	h.Memory = 1024
+
+	if !h.MustUpdate("argon2$argon2i$v=19$m=512,t=2,p=2$NnFZNGxmQTE1bmFV$kPPGrqD6dnRllcQeksFN+w") {
+		t.Fatal("Hash with different memory should be updated.")
+	}
+
+	h.Memory = 512
+	h.SaltEntropy = 128
+
+	if !h.MustUpdate("argon2$argon2i$v=19$m=512,t=2,p=2$NnFZNGxmQTE1bmFV$kPPGrqD6dnRllcQeksFN+w") {
+		t.Fatal("Hash with less salt entropy should be updated.")
+	}
+}
+
+func TestArgon2HardenRuntime(t *testing.T) {
+	h := NewArgon2Hasher()
+	h.Time = 3
+
+	if err := h.HardenRuntime("admin", "argon2$argon2i$v=19$m=512,t=2,p=2$NnFZNGxmQTE1bmFV$kPPGrqD6dnRllcQeksFN+w"); err != nil {
+		t.Fatalf("HardenRuntime error: %s", err)
+	}
+}
+
+func TestArgon2idEncode(t *testing.T) {
+	encoded, err := NewArgon2Hasher().Encode("admin", "6qY4lfA15naU")
+
+	if err != nil {
+		t.Fatalf("Encode error: %s", err)
+	}
+
+	if !strings.HasPrefix(encoded, "argon2$argon2id$v=19$m=102400,t=2,p=8$NnFZNGxmQTE1bmFV$") {
+		t.Fatalf("Encoded hash %s is not argon2id.", encoded)
+	}
+
+	valid, err := NewArgon2Hasher().Verify("admin", encoded)
+
+	if err != nil {
+		t.Fatalf("Verify error: %s", err)
+	}
+
+	if !valid {
+		t.Fatal("Password should be valid.")
+	}
+}
+
+func TestArgon2idVerify(t *testing.T) {
+	valid, err := newArgon2iHasher().Verify("admin", "argon2$argon2id$v=19$m=512,t=2,p=2$NnFZNGxmQTE1bmFV$/noD0KSu5OEnoJHi1E4KFA")
+
+	if err != nil {
+		t.Fatalf("Verify error: %s", err)
+	}
+
+	if !valid {
+		t.Fatal("Password should be valid.")
+	}
+}
+
+func TestArgon2VerifyUnsupportedVariant(t *testing.T) {
+	_, err := NewArgon2Hasher().Verify("admin", "argon2$argon2d$v=19$m=512,t=2,p=2$NnFZNGxmQTE1bmFV$/noD0KSu5OEnoJHi1E4KFA")
+
+	if !errors.Is(err, ErrAlgorithmMismatch) {
+		t.Fatalf("Error %v is not %s.", err, ErrAlgorithmMismatch)
+	}
+}
+
+func TestArgon2VerifyLimits(t *testing.T) {
+	tests := []struct {
+		encoded string
+		hasher  func(h *Argon2Hasher)
+	}{
+		{"argon2$argon2id$v=19$m=4294967295,t=2,p=2$NnFZNGxmQTE1bmFV$/noD0KSu5OEnoJHi1E4KFA", func(h *Argon2Hasher) {}},
+		{"argon2$argon2id$v=19$m=512,t=1000000,p=2$NnFZNGxmQTE1bmFV$/noD0KSu5OEnoJHi1E4KFA", func(h *Argon2Hasher) {}},
+		{"argon2$argon2id$v=19$m=512,t=2,p=255$NnFZNGxmQTE1bmFV$/noD0KSu5OEnoJHi1E4KFA", func(h *Argon2Hasher) {}},
+		{"argon2$argon2id$v=19$m=512,t=2,p=2$NnFZNGxmQTE1bmFV$/noD0KSu5OEnoJHi1E4KFA", func(h *Argon2Hasher) { h.MaxLength = 8 }},
+		{"argon2$argon2id$v=19$m=512,t=2,p=2$NnFZNGxmQTE1bmFV$/noD0KSu5OEnoJHi1E4KFA", func(h *Argon2Hasher) { h.MaxMemory = 256 }},
+	}
+
+	for _, test := range tests {
+		h := NewArgon2Hasher()
+		test.hasher(h)
+
+		_, err := h.Verify("admin", test.encoded)
+
+		if !errors.Is(err, ErrLimitExceeded) {
+			t.Fatalf("Error %v is not %s for %s.", err, ErrLimitExceeded, test.encoded)
+		}
+	}
+}
+
+func TestArgon2VerifyInvalidParameters(t *testing.T) {
+	for _, encoded := range []string{
+		"argon2$argon2id$v=19$m=512,t=0,p=2$NnFZNGxmQTE1bmFV$/noD0KSu5OEnoJHi1E4KFA",
+		"argon2$argon2id$v=19$m=512,t=2,p=0$NnFZNGxmQTE1bmFV$/noD0KSu5OEnoJHi1E4KFA",
+		"argon2$argon2id$v=19$m=512,t=2,p=2$NnFZNGxmQTE1bmFV$",
+	} {
+		_, err := NewArgon2Hasher().Verify("admin", encoded)
+
+		if !errors.Is(err, ErrInvalidParameters) {
+			t.Fatalf("Error %v is not %s for %s.", err, ErrInvalidParameters, encoded)
+		}
+	}
+}
@@ -2,21 +2,50 @@ package bcrypt
 
 import (
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/hex"
-	"errors"
 	"fmt"
 	"hash"
+	"strconv"
 	"strings"
 
+	"github.com/alexandrevicenzi/unchained/internal/hasherr"
 	"golang.org/x/crypto/bcrypt"
 )
 
 // Errors returned by BCryptHasher.
+//
+// Errors about a component of an encoded password are wrapped
+// in an unchained.HashError, match them with errors.Is.
 var (
-	ErrHashComponentMismatch = errors.New("unchained/bcrypt: hashed password components mismatch")
-	ErrAlgorithmMismatch     = errors.New("unchained/bcrypt: algorithm mismatch")
+	ErrHashComponentUnreadable = hasherr.New(hasherr.ErrMalformedHash, "unchained/bcrypt: unreadable component in hashed password")
+	ErrHashComponentMismatch   = hasherr.New(hasherr.ErrMalformedHash, "unchained/bcrypt: hashed password components mismatch")
+	ErrAlgorithmMismatch       = hasherr.New(hasherr.ErrUnsupportedAlgorithm, "unchained/bcrypt: algorithm mismatch")
+	ErrInvalidSalt             = hasherr.New(hasherr.ErrInvalidSalt, "unchained/bcrypt: invalid salt")
+	ErrInvalidCost             = hasherr.New(hasherr.ErrInvalidParameters, "unchained/bcrypt: invalid cost")
+	ErrIncompatibleVersion     = hasherr.New(hasherr.ErrUnsupportedVersion, "unchained/bcrypt: incompatible version")
+	ErrLimitExceeded           = hasherr.New(hasherr.ErrLimitExceeded, "unchained/bcrypt: cost exceeds limit")
+	ErrPasswordTooLong         = hasherr.New(hasherr.ErrPasswordTooLong, "unchained/bcrypt: password too long")
+	ErrPasswordTruncated       = hasherr.New(hasherr.ErrPasswordTooLong, "unchained/bcrypt: password longer than 72 bytes would be truncated")
 )
 
+// DefaultMaxCost is the limit used by Verify when MaxCost is zero.
+const DefaultMaxCost = 16
+
+// DecodedHash holds the components of a bcrypt encoded password.
+type DecodedHash struct {
+	// Algorithm identifier.
+	Algorithm string
+	// Bcrypt version, e.g. 2b.
+	Version string
+	// Number of rounds used to encode the password.
+	Cost int
+	// Bcrypt base64 encoded salt.
+	Salt string
+	// Bcrypt base64 encoded hash.
+	Hash string
+}
+
 // BCryptHasher implements Bcrypt password hasher.
 type BCryptHasher struct {
 	// Algorithm identifier.
@@ -25,18 +54,56 @@ type BCryptHasher struct {
 	Digest func() hash.Hash
 	// Defines the number of rounds used to encode the password.
 	Cost int
+	// Defines the maximum cost accepted by Verify, zero means DefaultMaxCost.
+	MaxCost int
+	// Defines the maximum length of passwords in bytes, zero means no limit.
+	MaxPasswordLength int
+	// Defines whether passwords longer than 72 bytes, after Digest if any,
+	// are rejected with ErrPasswordTruncated instead of being truncated.
+	RejectTruncation bool
 }
 
 // Encode turns a plain-text password into a hash.
 //
-// Parameter salt is currently ignored.
+// Salt must be 16 bytes encoded as 22 characters of bcrypt's base64 alphabet,
+// optionally prefixed by version and cost as returned by Python's bcrypt.gensalt(),
+// e.g. $2b$12$. If salt is empty, a random salt is used.
 func (h *BCryptHasher) Encode(password string, salt string) (string, error) {
+	if h.MaxPasswordLength > 0 && len(password) > h.MaxPasswordLength {
+		return "", ErrPasswordTooLong
+	}
+
 	if h.Digest != nil {
 		d := h.Digest()
 		d.Write([]byte(password))
 		password = hex.EncodeToString(d.Sum(nil))
 	}
 
+	if h.RejectTruncation && len(password) > maxPasswordSize {
+		return "", ErrPasswordTruncated
+	}
+
+	if salt != "" {
+		version, cost, raw, err := parseSalt(salt, h.Cost)
+
+		if err != nil {
+			return "", hasherr.Wrap(h.Algorithm, "salt", err)
+		}
+
+		hash, err := hashWithSalt([]byte(password), version, cost, raw)
+
+		if err != nil {
+			return "", hasherr.Wrap(h.Algorithm, "work_factor", err)
+		}
+
+		return fmt.Sprintf("%s$%s", h.Algorithm, hash), nil
+	}
+
+	// GenerateFromPassword silently uses its default cost below minCost.
+	if h.Cost < minCost || h.Cost > maxCost {
+		return "", hasherr.Wrap(h.Algorithm, "work_factor", ErrInvalidCost)
+	}
+
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
 
 	if err != nil {
@@ -46,28 +113,147 @@ func (h *BCryptHasher) Encode(password string, salt string) (string, error) {
 	return fmt.Sprintf("%s$%s", h.Algorithm, string(bytes)), nil
 }
 
+// Decode splits the encoded digest into its components.
+func (h *BCryptHasher) Decode(encoded string) (*DecodedHash, error) {
+	s := strings.SplitN(encoded, "$", 5)
+
+	if len(s) != 5 || s[1] != "" || len(s[4]) != 53 {
+		return nil, ErrHashComponentMismatch
+	}
+
+	algorithm, version, cost, data := s[0], s[2], s[3], s[4]
+
+	if algorithm != h.Algorithm {
+		return nil, hasherr.Wrap(h.Algorithm, "algorithm", ErrAlgorithmMismatch)
+	}
+
+	c, err := strconv.Atoi(cost)
+
+	if err != nil {
+		return nil, hasherr.Wrap(h.Algorithm, "work_factor", ErrHashComponentUnreadable)
+	}
+
+	return &DecodedHash{
+		Algorithm: algorithm,
+		Version:   version,
+		Cost:      c,
+		Salt:      data[:22],
+		Hash:      data[22:],
+	}, nil
+}
+
 // Verify if a plain-text password matches the encoded digest.
+//
+// Malformed digests, unsupported versions and invalid costs
+// are reported as errors instead of a mismatch.
 func (h *BCryptHasher) Verify(password string, encoded string) (bool, error) {
-	s := strings.SplitN(encoded, "$", 2)
+	if h.MaxPasswordLength > 0 && len(password) > h.MaxPasswordLength {
+		return false, ErrPasswordTooLong
+	}
+
+	d, err := h.Decode(encoded)
+
+	if err != nil {
+		return false, err
+	}
 
-	if len(s) != 2 {
-		return false, ErrHashComponentMismatch
+	if !isSupportedVersion(d.Version) {
+		return false, hasherr.Wrap(h.Algorithm, "version", ErrIncompatibleVersion)
 	}
 
-	algorithm, hash := s[0], s[1]
+	if d.Cost < minCost || d.Cost > maxCost {
+		return false, hasherr.Wrap(h.Algorithm, "work_factor", ErrInvalidCost)
+	}
 
-	if algorithm != h.Algorithm {
-		return false, ErrAlgorithmMismatch
+	max := h.MaxCost
+
+	if max <= 0 {
+		max = DefaultMaxCost
+	}
+
+	if d.Cost > max {
+		return false, hasherr.Wrap(h.Algorithm, "work_factor", ErrLimitExceeded)
+	}
+
+	salt, err := bcryptEncoding.DecodeString(d.Salt)
+
+	if err != nil {
+		return false, hasherr.Wrap(h.Algorithm, "salt", ErrHashComponentUnreadable)
+	}
+
+	if _, err := bcryptEncoding.DecodeString(d.Hash); err != nil {
+		return false, hasherr.Wrap(h.Algorithm, "hash", ErrHashComponentUnreadable)
 	}
 
 	if h.Digest != nil {
-		d := h.Digest()
-		d.Write([]byte(password))
-		password = hex.EncodeToString(d.Sum(nil))
+		digest := h.Digest()
+		digest.Write([]byte(password))
+		password = hex.EncodeToString(digest.Sum(nil))
+	}
+
+	if h.RejectTruncation && len(password) > maxPasswordSize {
+		return false, ErrPasswordTruncated
+	}
+
+	hash, err := hashWithSalt([]byte(password), d.Version, d.Cost, salt)
+
+	if err != nil {
+		return false, err
+	}
+
+	// Only the hash is compared, like bcrypt, as the salt may be
+	// encoded with unused trailing bits.
+	newHash := hash[len(hash)-len(d.Hash):]
+
+	return subtle.ConstantTimeCompare([]byte(newHash), []byte(d.Hash)) == 1, nil
+}
+
+// MustUpdate returns true if the encoded digest uses
+// a different cost than the hasher.
+func (h *BCryptHasher) MustUpdate(encoded string) bool {
+	d, err := h.Decode(encoded)
+
+	if err != nil {
+		return false
+	}
+
+	return d.Cost != h.Cost
+}
+
+// HardenRuntime encodes the password again as many times as needed
+// to match the cost of the hasher, so verification takes the same time
+// regardless of the cost used to encode the password.
+func (h *BCryptHasher) HardenRuntime(password string, encoded string) error {
+	d, err := h.Decode(encoded)
+
+	if err != nil {
+		return err
+	}
+
+	if d.Cost < minCost || d.Cost > maxCost {
+		return hasherr.Wrap(h.Algorithm, "work_factor", ErrInvalidCost)
+	}
+
+	if d.Cost >= h.Cost {
+		return nil
+	}
+
+	if h.Digest != nil {
+		digest := h.Digest()
+		digest.Write([]byte(password))
+		password = hex.EncodeToString(digest.Sum(nil))
+	}
+
+	// The cost is logarithmic, adding one doubles the work.
+	for diff := 1<<uint(h.Cost-d.Cost) - 1; diff > 0; diff-- {
+		_, err = bcrypt.GenerateFromPassword([]byte(password), d.Cost)
+
+		if err != nil {
+			return err
+		}
 	}
 
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil, nil
+	return nil
 }
 
 // NewBCryptHasher secures password hashing using the bcrypt algorithm.
@@ -0,0 +1,40 @@
+//go:build go1.18
+// +build go1.18
+
+package bcrypt
+
+import "testing"
+
+func FuzzBCryptVerify(f *testing.F) {
+	f.Add("bcrypt$$2b$04$qcNExitVe89wMG.nmRD4Qupn2hFm0pxvnu6VC.w6LShOx30l.F9/.")
+	f.Add("bcrypt$$2a$04$qcNExitVe89wMG.nmRD4Qu")
+	f.Add("bcrypt$$2y$-1$qcNExitVe89wMG.nmRD4Qupn2hFm0pxvnu6VC.w6LShOx30l.F9/.")
+
+	f.Fuzz(func(t *testing.T, encoded string) {
+		h := NewBCryptHasher()
+		h.Cost = 4
+		h.MaxCost = 4
+
+		h.Decode(encoded)
+		h.Verify("admin", encoded)
+		h.MustUpdate(encoded)
+		h.HardenRuntime("admin", encoded)
+	})
+}
+
+func FuzzBCryptEncodeSalt(f *testing.F) {
+	f.Add("qcNExitVe89wMG.nmRD4Qu")
+	f.Add("$2b$04$qcNExitVe89wMG.nmRD4Qu")
+
+	f.Fuzz(func(t *testing.T, salt string) {
+		h := NewBCryptHasher()
+		h.Cost = 4
+
+		// Skip salts that would take too long.
+		if _, cost, _, err := parseSalt(salt, h.Cost); err == nil && cost > h.Cost {
+			return
+		}
+
+		h.Encode("admin", salt)
+	})
+}
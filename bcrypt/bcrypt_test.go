@@ -1,6 +1,7 @@
 package bcrypt
 
 import (
+	"errors"
 	"strings"
 	"testing"
 )
@@ -148,3 +149,252 @@ func TestBCryptSHA265VerifyInvalidPassword(t *testing.T) {
 		t.Fatal("Password should not be valid.")
 	}
 }
+
+func TestBCryptDecode(t *testing.T) {
+	d, err := NewBCryptHasher().Decode("bcrypt$$2b$12$qcNExitVe89wMG.nmRD4Qupn2hFm0pxvnu6VC.w6LShOx30l.F9/.")
+
+	if err != nil {
+		t.Fatalf("Decode error: %s", err)
+	}
+
+	if d.Version != "2b" || d.Cost != 12 || d.Salt != "qcNExitVe89wMG.nmRD4Qu" || d.Hash != "pn2hFm0pxvnu6VC.w6LShOx30l.F9/." {
+		t.Fatalf("Decoded hash %+v does not match.", d)
+	}
+}
+
+func TestBCryptMustUpdate(t *testing.T) {
+	h := NewBCryptHasher()
+
+	if h.MustUpdate("bcrypt$$2b$12$qcNExitVe89wMG.nmRD4Qupn2hFm0pxvnu6VC.w6LShOx30l.F9/.") {
+		t.Fatal("Hash with same cost should not be updated.")
+	}
+
+	h.Cost = 13
+
+	if !h.MustUpdate("bcrypt$$2b$12$qcNExitVe89wMG.nmRD4Qupn2hFm0pxvnu6VC.w6LShOx30l.F9/.") {
+		t.Fatal("Hash with different cost should be updated.")
+	}
+}
+
+func TestBCryptHardenRuntime(t *testing.T) {
+	h := NewBCryptHasher()
+	h.Cost = 5
+	encoded, err := h.Encode("admin", "")
+
+	if err != nil {
+		t.Fatalf("Encode error: %s", err)
+	}
+
+	h.Cost = 6
+
+	if err := h.HardenRuntime("admin", encoded); err != nil {
+		t.Fatalf("HardenRuntime error: %s", err)
+	}
+}
+
+func TestBCryptEncodeWithSalt1(t *testing.T) {
+	encoded, err := NewBCryptHasher().Encode("admin", "qcNExitVe89wMG.nmRD4Qu")
+
+	if err != nil {
+		t.Fatalf("Encode error: %s", err)
+	}
+
+	expected := "bcrypt$$2b$12$qcNExitVe89wMG.nmRD4Qupn2hFm0pxvnu6VC.w6LShOx30l.F9/."
+
+	if encoded != expected {
+		t.Fatalf("Encoded hash %s does not match %s.", encoded, expected)
+	}
+}
+
+func TestBCryptEncodeWithSalt2(t *testing.T) {
+	encoded, err := NewBCryptHasher().Encode("this$is#my@PASSWORD", "$2b$12$HDMQLhINvA1bGpihQwjCzu")
+
+	if err != nil {
+		t.Fatalf("Encode error: %s", err)
+	}
+
+	expected := "bcrypt$$2b$12$HDMQLhINvA1bGpihQwjCzuA4deBmPQvwj85ehmi5RgJqzM5OnNQRy"
+
+	if encoded != expected {
+		t.Fatalf("Encoded hash %s does not match %s.", encoded, expected)
+	}
+}
+
+func TestBCryptSHA256EncodeWithSalt(t *testing.T) {
+	encoded, err := NewBCryptSHA256Hasher().Encode("admin", "WZK9cb9qKN.Q5LCYPq/gj.")
+
+	if err != nil {
+		t.Fatalf("Encode error: %s", err)
+	}
+
+	expected := "bcrypt_sha256$$2b$12$WZK9cb9qKN.Q5LCYPq/gj.6gvry1b37HUsJER6KhQBnIWmPyyaaqi"
+
+	if encoded != expected {
+		t.Fatalf("Encoded hash %s does not match %s.", encoded, expected)
+	}
+}
+
+func TestBCryptEncodeInvalidSalt(t *testing.T) {
+	for _, salt := range []string{"salt", "$2c$12$qcNExitVe89wMG.nmRD4Qu", "$2b$xx$qcNExitVe89wMG.nmRD4Qu", "qcNExitVe89wMG.nmRD4Q!"} {
+		_, err := NewBCryptHasher().Encode("admin", salt)
+
+		if !errors.Is(err, ErrInvalidSalt) {
+			t.Fatalf("Error %v is not %s for salt %s.", err, ErrInvalidSalt, salt)
+		}
+	}
+}
+
+func TestBCryptEncodeInvalidCost(t *testing.T) {
+	_, err := NewBCryptHasher().Encode("admin", "$2b$03$qcNExitVe89wMG.nmRD4Qu")
+
+	if !errors.Is(err, ErrInvalidCost) {
+		t.Fatalf("Error %v is not %s.", err, ErrInvalidCost)
+	}
+}
+
+func TestBCryptNewSalt(t *testing.T) {
+	salt, err := NewSalt()
+
+	if err != nil {
+		t.Fatalf("NewSalt error: %s", err)
+	}
+
+	h := NewBCryptHasher()
+	h.Cost = 4
+	encoded, err := h.Encode("admin", salt)
+
+	if err != nil {
+		t.Fatalf("Encode error: %s", err)
+	}
+
+	valid, err := h.Verify("admin", encoded)
+
+	if err != nil {
+		t.Fatalf("Verify error: %s", err)
+	}
+
+	if !valid {
+		t.Fatal("Password should be valid.")
+	}
+}
+
+func TestBCryptVerifyInvalidPasswordNoError(t *testing.T) {
+	valid, err := NewBCryptHasher().Verify("wrong", "bcrypt$$2b$12$qcNExitVe89wMG.nmRD4Qupn2hFm0pxvnu6VC.w6LShOx30l.F9/.")
+
+	if err != nil {
+		t.Fatalf("Verify error: %s", err)
+	}
+
+	if valid {
+		t.Fatal("Password should not be valid.")
+	}
+}
+
+func TestBCryptVerifyRandomSalt(t *testing.T) {
+	h := NewBCryptSHA256Hasher()
+	h.Cost = 4
+	encoded, err := h.Encode("admin", "")
+
+	if err != nil {
+		t.Fatalf("Encode error: %s", err)
+	}
+
+	valid, err := h.Verify("admin", encoded)
+
+	if err != nil {
+		t.Fatalf("Verify error: %s", err)
+	}
+
+	if !valid {
+		t.Fatal("Password should be valid.")
+	}
+}
+
+func TestBCryptVerifyMalformed(t *testing.T) {
+	tests := []struct {
+		encoded string
+		err     error
+	}{
+		{"bcrypt$$2b$12$qcNExitVe89wMG.nmRD4Qupn2hFm0pxvnu6VC.w6LShOx30l", ErrHashComponentMismatch},
+		{"bcrypt$2b$12$qcNExitVe89wMG.nmRD4Qupn2hFm0pxvnu6VC.w6LShOx30l.F9/.", ErrHashComponentMismatch},
+		{"bcrypt$$3a$12$qcNExitVe89wMG.nmRD4Qupn2hFm0pxvnu6VC.w6LShOx30l.F9/.", ErrIncompatibleVersion},
+		{"bcrypt$$2b$xx$qcNExitVe89wMG.nmRD4Qupn2hFm0pxvnu6VC.w6LShOx30l.F9/.", ErrHashComponentUnreadable},
+		{"bcrypt$$2b$32$qcNExitVe89wMG.nmRD4Qupn2hFm0pxvnu6VC.w6LShOx30l.F9/.", ErrInvalidCost},
+		{"bcrypt$$2b$03$qcNExitVe89wMG.nmRD4Qupn2hFm0pxvnu6VC.w6LShOx30l.F9/.", ErrInvalidCost},
+		{"bcrypt$$2b$12$qcNExitVe89wMG.nmRD4Q!pn2hFm0pxvnu6VC.w6LShOx30l.F9/.", ErrHashComponentUnreadable},
+		{"bcrypt$$2b$12$qcNExitVe89wMG.nmRD4Qupn2hFm0pxvnu6VC.w6LShOx30l.F9/!", ErrHashComponentUnreadable},
+	}
+
+	for _, test := range tests {
+		valid, err := NewBCryptHasher().Verify("admin", test.encoded)
+
+		if !errors.Is(err, test.err) {
+			t.Fatalf("Error %v is not %s for %s.", err, test.err, test.encoded)
+		}
+
+		if valid {
+			t.Fatal("Password should not be valid.")
+		}
+	}
+}
+
+func TestBCryptVerifyLimit(t *testing.T) {
+	h := NewBCryptHasher()
+	h.MaxCost = 10
+
+	_, err := h.Verify("admin", "bcrypt$$2b$12$qcNExitVe89wMG.nmRD4Qupn2hFm0pxvnu6VC.w6LShOx30l.F9/.")
+
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("Error %v is not %s.", err, ErrLimitExceeded)
+	}
+
+	_, err = NewBCryptHasher().Verify("admin", "bcrypt$$2b$31$qcNExitVe89wMG.nmRD4Qupn2hFm0pxvnu6VC.w6LShOx30l.F9/.")
+
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("Error %v is not %s.", err, ErrLimitExceeded)
+	}
+}
+
+func TestBCryptRejectTruncation(t *testing.T) {
+	password := strings.Repeat("a", 73)
+
+	h := NewBCryptHasher()
+	h.Cost = 4
+
+	encoded, err := h.Encode(password, "qcNExitVe89wMG.nmRD4Qu")
+
+	if err != nil {
+		t.Fatalf("Encode error: %s", err)
+	}
+
+	h.RejectTruncation = true
+
+	if _, err := h.Encode(password, "qcNExitVe89wMG.nmRD4Qu"); err != ErrPasswordTruncated {
+		t.Fatalf("Error %v is not %s.", err, ErrPasswordTruncated)
+	}
+
+	if _, err := h.Verify(password, encoded); err != ErrPasswordTruncated {
+		t.Fatalf("Error %v is not %s.", err, ErrPasswordTruncated)
+	}
+
+	h = NewBCryptSHA256Hasher()
+	h.Cost = 4
+	h.RejectTruncation = true
+
+	if _, err := h.Encode(password, "qcNExitVe89wMG.nmRD4Qu"); err != nil {
+		t.Fatalf("Encode error: %s", err)
+	}
+}
+
+func TestBCryptMaxPasswordLength(t *testing.T) {
+	h := NewBCryptSHA256Hasher()
+	h.MaxPasswordLength = 4
+
+	if _, err := h.Encode("admin", "qcNExitVe89wMG.nmRD4Qu"); err != ErrPasswordTooLong {
+		t.Fatalf("Error %v is not %s.", err, ErrPasswordTooLong)
+	}
+
+	if _, err := h.Verify("admin", "bcrypt_sha256$$2b$12$WZK9cb9qKN.Q5LCYPq/gj.6gvry1b37HUsJER6KhQBnIWmPyyaaqi"); err != ErrPasswordTooLong {
+		t.Fatalf("Error %v is not %s.", err, ErrPasswordTooLong)
+	}
+}
@@ -0,0 +1,138 @@
+package bcrypt
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/blowfish"
+)
+
+// This file implements the bcrypt algorithm with a caller supplied salt,
+// which "golang.org/x/crypto/bcrypt" does not allow.
+
+const (
+	// Length of the bcrypt base64 encoded salt.
+	encodedSaltSize = 22
+	// Length of the raw salt in bytes.
+	saltSize = 16
+	// Bytes of the password used by bcrypt, the rest is ignored.
+	maxPasswordSize = 72
+	// Minimum and maximum bcrypt cost.
+	minCost = 4
+	maxCost = 31
+)
+
+// bcrypt uses its own base64 alphabet, without padding.
+var bcryptEncoding = base64.NewEncoding("./ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789").WithPadding(base64.NoPadding)
+
+// "OrpheanBeholderScryDoubt", the text encrypted by bcrypt.
+var magicCipherData = []byte{
+	0x4f, 0x72, 0x70, 0x68,
+	0x65, 0x61, 0x6e, 0x42,
+	0x65, 0x68, 0x6f, 0x6c,
+	0x64, 0x65, 0x72, 0x53,
+	0x63, 0x72, 0x79, 0x44,
+	0x6f, 0x75, 0x62, 0x74,
+}
+
+// NewSalt returns a random salt encoded with bcrypt's base64 alphabet.
+func NewSalt() (string, error) {
+	b := make([]byte, saltSize)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return bcryptEncoding.EncodeToString(b), nil
+}
+
+// isSupportedVersion returns true for the bcrypt versions
+// that produce the same hashes, 2a, 2b and 2y.
+func isSupportedVersion(version string) bool {
+	switch version {
+	case "2a", "2b", "2y":
+		return true
+	}
+
+	return false
+}
+
+// parseSalt accepts either a 22 characters salt or a salt in the format
+// returned by Python's bcrypt.gensalt(), e.g. $2b$12$<22 characters>,
+// in which case the version and cost of the salt are used.
+func parseSalt(salt string, cost int) (version string, c int, raw []byte, err error) {
+	version, c = "2b", cost
+
+	if strings.HasPrefix(salt, "$") {
+		s := strings.Split(salt, "$")
+
+		if len(s) != 4 || s[0] != "" {
+			return "", 0, nil, ErrInvalidSalt
+		}
+
+		if !isSupportedVersion(s[1]) {
+			return "", 0, nil, ErrInvalidSalt
+		}
+
+		if c, err = strconv.Atoi(s[2]); err != nil {
+			return "", 0, nil, ErrInvalidSalt
+		}
+
+		version, salt = s[1], s[3]
+	}
+
+	if len(salt) != encodedSaltSize {
+		return "", 0, nil, ErrInvalidSalt
+	}
+
+	raw, err = bcryptEncoding.DecodeString(salt)
+
+	if err != nil || len(raw) != saltSize {
+		return "", 0, nil, ErrInvalidSalt
+	}
+
+	return version, c, raw, nil
+}
+
+// hashWithSalt returns the bcrypt hash in the modular crypt format,
+// e.g. $2b$12$<22 characters salt><31 characters hash>.
+func hashWithSalt(password []byte, version string, cost int, salt []byte) (string, error) {
+	if cost < minCost || cost > maxCost {
+		return "", ErrInvalidCost
+	}
+
+	// Like C implementations, the trailing NUL is part of the key.
+	key := make([]byte, len(password)+1)
+	copy(key, password)
+
+	c, err := blowfish.NewSaltedCipher(key, salt)
+
+	if err != nil {
+		return "", err
+	}
+
+	for i, rounds := 0, 1<<uint(cost); i < rounds; i++ {
+		blowfish.ExpandKey(key, c)
+		blowfish.ExpandKey(salt, c)
+	}
+
+	data := make([]byte, len(magicCipherData))
+	copy(data, magicCipherData)
+
+	for i := 0; i < len(data); i += 8 {
+		for j := 0; j < 64; j++ {
+			c.Encrypt(data[i:i+8], data[i:i+8])
+		}
+	}
+
+	// Like C implementations, only 23 of the 24 bytes are encoded.
+	return fmt.Sprintf("$%s$%02d$%s%s",
+		version,
+		cost,
+		bcryptEncoding.EncodeToString(salt),
+		bcryptEncoding.EncodeToString(data[:23]),
+	), nil
+}
@@ -2,9 +2,6 @@
 //
 // This is considered by many to be the most secure algorithm.
 //
-// This library does not allow to set custom salt as in the Django algorithm.
-// If you encode the same password multiple times you will get different hashes.
-// This limitation comes from "golang.org/x/crypto/bcrypt" library.
-//
-// A future release of this library may add support to custom salt.
+// Custom salts are supported as in the Django algorithm, so the same password
+// and salt always produce the same hash. Without a salt, a random one is used.
 package bcrypt
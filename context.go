@@ -0,0 +1,136 @@
+package unchained
+
+import "context"
+
+// Context holds an ordered list of hashers, like Django's PASSWORD_HASHERS setting.
+//
+// The first hasher is used to encode new passwords,
+// the others are only accepted to verify existing passwords.
+type Context struct {
+	hashers []PasswordHasher
+}
+
+// NewContext returns a Context that uses the given hashers.
+//
+// The first hasher is the preferred one.
+func NewContext(hashers ...PasswordHasher) (*Context, error) {
+	if len(hashers) == 0 {
+		return nil, ErrNoHashers
+	}
+
+	list := make([]PasswordHasher, len(hashers))
+	copy(list, hashers)
+
+	return &Context{hashers: list}, nil
+}
+
+// Hashers returns the hashers of the context in order of preference.
+func (c *Context) Hashers() []PasswordHasher {
+	list := make([]PasswordHasher, len(c.hashers))
+	copy(list, c.hashers)
+	return list
+}
+
+// LookupHasher returns the hasher of the context for the algorithm.
+//
+// If algorithm is "default", the preferred hasher is returned.
+func (c *Context) LookupHasher(algorithm string) (PasswordHasher, error) {
+	if algorithm == "default" {
+		return c.hashers[0], nil
+	}
+
+	for _, h := range c.hashers {
+		if h.Algorithm() == algorithm {
+			return h, nil
+		}
+	}
+
+	if IsHasherImplemented(algorithm) {
+		return nil, ErrHasherNotConfigured
+	}
+
+	if IsValidHasher(algorithm) {
+		return nil, ErrHasherNotImplemented
+	}
+
+	return nil, ErrInvalidHasher
+}
+
+// IdentifyHasher returns the hasher used in the encoded password.
+func (c *Context) IdentifyHasher(encoded string) string {
+	return identifyHasher(encoded, c.hashers)
+}
+
+// Decode splits the encoded password into its components,
+// using the hasher of the context that produced it.
+func (c *Context) Decode(encoded string) (*DecodedHash, error) {
+	return decode(c, encoded)
+}
+
+// SafeSummary decodes the encoded password and masks its salt and hash,
+// so the result can be displayed.
+func (c *Context) SafeSummary(encoded string) (*DecodedHash, error) {
+	return safeSummary(c, encoded)
+}
+
+// SaltEntropy returns the entropy in bits of the salt of the encoded password,
+// using the hasher of the context that produced it.
+func (c *Context) SaltEntropy(encoded string) (float64, error) {
+	return saltEntropy(c, encoded)
+}
+
+// NeedsRehash returns true if the encoded password should be encoded again,
+// because it does not use the preferred hasher or its current settings.
+func (c *Context) NeedsRehash(encoded string) bool {
+	return needsRehash(encoded, c.IdentifyHasher(encoded), c.hashers[0])
+}
+
+// CheckPassword validates if the raw password matches the encoded digest.
+//
+// Only the hashers of the context are accepted.
+func (c *Context) CheckPassword(password, encoded string, opts ...Option) (bool, error) {
+	return checkPassword(context.Background(), c, password, encoded, nil, "default", newOptions(opts))
+}
+
+// CheckPasswordContext validates if the raw password matches the encoded digest.
+//
+// It behaves like CheckPassword, except that ctx.Err() is returned
+// as soon as ctx is done.
+func (c *Context) CheckPasswordContext(ctx context.Context, password, encoded string, opts ...Option) (bool, error) {
+	return checkPassword(ctx, c, password, encoded, nil, "default", newOptions(opts))
+}
+
+// CheckPasswordOrDummy validates if the raw password matches the encoded digest.
+//
+// If encoded is unusable, a random password is encoded once
+// with the preferred hasher and false is returned.
+func (c *Context) CheckPasswordOrDummy(password, encoded string, opts ...Option) (bool, error) {
+	return c.CheckPassword(password, encoded, append([]Option{WithDummyCheck()}, opts...)...)
+}
+
+// CheckPasswordWithSetter validates if the raw password matches the encoded digest
+// and calls setter with a new encoded password if the stored one must be updated.
+//
+// It behaves like the package level CheckPasswordWithSetter, except that
+// "default" refers to the preferred hasher of the context and
+// only the hashers of the context are accepted.
+func (c *Context) CheckPasswordWithSetter(password, encoded string, setter func(encoded string) error, preferred string, opts ...Option) (bool, error) {
+	return checkPassword(context.Background(), c, password, encoded, setter, preferred, newOptions(opts))
+}
+
+// MakePassword turns a plain-text password into a hash.
+//
+// It behaves like the package level MakePassword, except that
+// "default" refers to the preferred hasher of the context and
+// only the hashers of the context are accepted.
+func (c *Context) MakePassword(password, salt, hasher string, opts ...Option) (string, error) {
+	return makePassword(context.Background(), c, password, salt, hasher, newOptions(opts))
+}
+
+// MakePasswordContext turns a plain-text password into a hash.
+//
+// It behaves like MakePassword, except that ctx.Err() is returned
+// as soon as ctx is done.
+func (c *Context) MakePasswordContext(ctx context.Context, password, salt, hasher string, opts ...Option) (string, error) {
+	return makePassword(ctx, c, password, salt, hasher, newOptions(opts))
+}
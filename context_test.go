@@ -0,0 +1,93 @@
+package unchained
+
+import (
+	"testing"
+
+	"github.com/alexandrevicenzi/unchained/md5"
+	"github.com/alexandrevicenzi/unchained/pbkdf2"
+	"github.com/alexandrevicenzi/unchained/sha1"
+)
+
+func newTestContext(t *testing.T) *Context {
+	c, err := NewContext(
+		PBKDF2(pbkdf2.NewPBKDF2SHA1Hasher()),
+		SHA1(sha1.NewSHA1PasswordHasher()),
+		UnsaltedMD5(md5.NewUnsaltedMD5PasswordHasher()),
+	)
+
+	if err != nil {
+		t.Fatalf("NewContext error: %s", err)
+	}
+
+	return c
+}
+
+func TestNewContextWithoutHashers(t *testing.T) {
+	_, err := NewContext()
+
+	if err != ErrNoHashers {
+		t.Fatalf("Error %v is not %s.", err, ErrNoHashers)
+	}
+}
+
+func TestContextMakePasswordDefault(t *testing.T) {
+	encoded, err := newTestContext(t).MakePassword("admin", "1TMOT0Rohg3g", "default")
+
+	if err != nil {
+		t.Fatalf("MakePassword error: %s", err)
+	}
+
+	expected := "pbkdf2_sha1$216000$1TMOT0Rohg3g$9bYz2H5NDmo2A2FEw5vc7Z7jk5Y="
+
+	if encoded != expected {
+		t.Fatalf("Encoded hash %s does not match %s.", encoded, expected)
+	}
+}
+
+func TestContextMakePasswordNotConfigured(t *testing.T) {
+	_, err := newTestContext(t).MakePassword("admin", "", PBKDF2SHA256Hasher)
+
+	if err != ErrHasherNotConfigured {
+		t.Fatalf("Error %v is not %s.", err, ErrHasherNotConfigured)
+	}
+}
+
+func TestContextCheckPassword(t *testing.T) {
+	c := newTestContext(t)
+
+	for _, encoded := range []string{
+		"pbkdf2_sha1$120000$1TMOT0Rohg3g$zVJ4+gcRcano9Qks+kcsgKeRnVs=",
+		"sha1$7E3eUiuxfTHG$154faafaf5455924ad853c5f1630eaf062c135a7",
+		"21232f297a57a5a743894a0e4a801fc3",
+	} {
+		valid, err := c.CheckPassword("admin", encoded)
+
+		if err != nil {
+			t.Fatalf("CheckPassword error: %s", err)
+		}
+
+		if !valid {
+			t.Fatalf("Password should be valid: %s", encoded)
+		}
+	}
+}
+
+func TestContextCheckPasswordNotConfigured(t *testing.T) {
+	_, err := newTestContext(t).CheckPassword("admin", "md5$8CjhcHYaEGZQ$c7f218365947cecaac46415390d5cb6a")
+
+	if err != ErrHasherNotConfigured {
+		t.Fatalf("Error %v is not %s.", err, ErrHasherNotConfigured)
+	}
+}
+
+func TestContextIdentifyHasher(t *testing.T) {
+	c := newTestContext(t)
+
+	if hasher := c.IdentifyHasher("21232f297a57a5a743894a0e4a801fc3"); hasher != UnsaltedMD5Hasher {
+		t.Fatalf("Hasher %s is not %s.", hasher, UnsaltedMD5Hasher)
+	}
+
+	if hasher := c.IdentifyHasher("sha1$$d033e22ae348aeb5660fc2140aec35850c4da997"); hasher != SHA1Hasher {
+		t.Fatalf("Hasher %s is not %s.", hasher, SHA1Hasher)
+	}
+}
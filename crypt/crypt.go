@@ -0,0 +1,115 @@
+package crypt
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"strings"
+
+	"github.com/alexandrevicenzi/unchained/internal/hasherr"
+)
+
+// Errors returned by CryptPasswordHasher.
+//
+// Errors about a component of an encoded password are wrapped
+// in an unchained.HashError, match them with errors.Is.
+var (
+	ErrHashComponentMismatch = hasherr.New(hasherr.ErrMalformedHash, "unchained/crypt: hashed password components mismatch")
+	ErrAlgorithmMismatch     = hasherr.New(hasherr.ErrUnsupportedAlgorithm, "unchained/crypt: algorithm mismatch")
+	ErrInvalidSalt           = hasherr.New(hasherr.ErrInvalidSalt, "unchained/crypt: salt must be 2 characters of [./0-9A-Za-z]")
+	ErrPasswordTooLong       = hasherr.New(hasherr.ErrPasswordTooLong, "unchained/crypt: password too long")
+)
+
+// DecodedHash holds the components of a crypt encoded password.
+type DecodedHash struct {
+	// Algorithm identifier.
+	Algorithm string
+	// Salt used to encode the password, the first 2 characters of the hash.
+	Salt string
+	// Crypt hash, including the salt.
+	Hash string
+}
+
+// CryptPasswordHasher implements crypt password hasher.
+type CryptPasswordHasher struct {
+	// Algorithm identifier.
+	Algorithm string
+	// Defines the maximum length of passwords in bytes, zero means no limit.
+	MaxPasswordLength int
+}
+
+func isValidSalt(salt string) bool {
+	return len(salt) == 2 && index(salt[0]) >= 0 && index(salt[1]) >= 0
+}
+
+// Encode turns a plain-text password into a hash.
+//
+// Salt must be 2 characters long. Only the first 8 characters
+// of the password are used by the crypt algorithm.
+func (h *CryptPasswordHasher) Encode(password string, salt string) (string, error) {
+	if h.MaxPasswordLength > 0 && len(password) > h.MaxPasswordLength {
+		return "", ErrPasswordTooLong
+	}
+
+	if !isValidSalt(salt) {
+		return "", hasherr.Wrap(h.Algorithm, "salt", ErrInvalidSalt)
+	}
+
+	return fmt.Sprintf("%s$$%s%s", h.Algorithm, salt, desCrypt(password, salt)), nil
+}
+
+// Decode splits the encoded digest into its components.
+func (h *CryptPasswordHasher) Decode(encoded string) (*DecodedHash, error) {
+	s := strings.SplitN(encoded, "$", 3)
+
+	if len(s) != 3 || len(s[2]) != 13 {
+		return nil, ErrHashComponentMismatch
+	}
+
+	algorithm, hash := s[0], s[2]
+
+	if algorithm != h.Algorithm {
+		return nil, hasherr.Wrap(h.Algorithm, "algorithm", ErrAlgorithmMismatch)
+	}
+
+	// The salt is part of the hash, as in Django's crypt$$<hash> format.
+	if s[1] != "" {
+		return nil, hasherr.Wrap(h.Algorithm, "salt", ErrHashComponentMismatch)
+	}
+
+	return &DecodedHash{
+		Algorithm: algorithm,
+		Salt:      hash[:2],
+		Hash:      hash,
+	}, nil
+}
+
+// Verify if a plain-text password matches the encoded digest.
+func (h *CryptPasswordHasher) Verify(password string, encoded string) (bool, error) {
+	if h.MaxPasswordLength > 0 && len(password) > h.MaxPasswordLength {
+		return false, ErrPasswordTooLong
+	}
+
+	d, err := h.Decode(encoded)
+
+	if err != nil {
+		return false, err
+	}
+
+	if !isValidSalt(d.Salt) {
+		return false, hasherr.Wrap(h.Algorithm, "salt", ErrInvalidSalt)
+	}
+
+	data := d.Salt + desCrypt(password, d.Salt)
+
+	return subtle.ConstantTimeCompare([]byte(data), []byte(d.Hash)) == 1, nil
+}
+
+// NewCryptPasswordHasher secures password hashing using UNIX's crypt (not recommended).
+//
+// This algorithm is implemented because Django used to store passwords this way
+// and to accept such password hashes.
+func NewCryptPasswordHasher() *CryptPasswordHasher {
+	return &CryptPasswordHasher{
+		Algorithm: "crypt",
+	}
+}
@@ -0,0 +1,17 @@
+//go:build go1.18
+// +build go1.18
+
+package crypt
+
+import "testing"
+
+func FuzzCryptVerify(f *testing.F) {
+	f.Add("crypt$$abOV.DfJmdnYw")
+	f.Add("crypt$$!!OV.DfJmdnYw")
+
+	f.Fuzz(func(t *testing.T, encoded string) {
+		h := NewCryptPasswordHasher()
+		h.Decode(encoded)
+		h.Verify("admin", encoded)
+	})
+}
@@ -0,0 +1,116 @@
+package crypt
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCryptEncode1(t *testing.T) {
+	encoded, err := NewCryptPasswordHasher().Encode("admin", "ab")
+
+	if err != nil {
+		t.Fatalf("Encode error: %s", err)
+	}
+
+	expected := "crypt$$abOV.DfJmdnYw"
+
+	if encoded != expected {
+		t.Fatalf("Encoded hash %s does not match %s.", encoded, expected)
+	}
+}
+
+func TestCryptEncode2(t *testing.T) {
+	encoded, err := NewCryptPasswordHasher().Encode("this-is-my-password", "X7")
+
+	if err != nil {
+		t.Fatalf("Encode error: %s", err)
+	}
+
+	expected := "crypt$$X7L3f2Vm9ufhI"
+
+	if encoded != expected {
+		t.Fatalf("Encoded hash %s does not match %s.", encoded, expected)
+	}
+}
+
+func TestCryptEncode3(t *testing.T) {
+	encoded, err := NewCryptPasswordHasher().Encode("Th1S1sMYp4ssw0rd", "./")
+
+	if err != nil {
+		t.Fatalf("Encode error: %s", err)
+	}
+
+	expected := "crypt$$./MJMhmZ1O2rQ"
+
+	if encoded != expected {
+		t.Fatalf("Encoded hash %s does not match %s.", encoded, expected)
+	}
+}
+
+func TestCryptEncode4(t *testing.T) {
+	encoded, err := NewCryptPasswordHasher().Encode("this$is#my@PASSWORD", "zZ")
+
+	if err != nil {
+		t.Fatalf("Encode error: %s", err)
+	}
+
+	expected := "crypt$$zZEPV5tC5Jog2"
+
+	if encoded != expected {
+		t.Fatalf("Encoded hash %s does not match %s.", encoded, expected)
+	}
+}
+
+func TestCryptEncodeInvalidSalt(t *testing.T) {
+	for _, salt := range []string{"", "a", "abc", "a$"} {
+		_, err := NewCryptPasswordHasher().Encode("admin", salt)
+
+		if !errors.Is(err, ErrInvalidSalt) {
+			t.Fatalf("Error %v is not %s.", err, ErrInvalidSalt)
+		}
+	}
+}
+
+func TestCryptVerify(t *testing.T) {
+	valid, err := NewCryptPasswordHasher().Verify("admin", "crypt$$abOV.DfJmdnYw")
+
+	if err != nil {
+		t.Fatalf("Verify error: %s", err)
+	}
+
+	if !valid {
+		t.Fatal("Password should be valid.")
+	}
+}
+
+func TestCryptVerifyInvalidPassword(t *testing.T) {
+	valid, err := NewCryptPasswordHasher().Verify("wrongpassword", "crypt$$abOV.DfJmdnYw")
+
+	if err != nil {
+		t.Fatalf("Verify error: %s", err)
+	}
+
+	if valid {
+		t.Fatal("Password should not be valid.")
+	}
+}
+
+func TestCryptDecode(t *testing.T) {
+	d, err := NewCryptPasswordHasher().Decode("crypt$$abOV.DfJmdnYw")
+
+	if err != nil {
+		t.Fatalf("Decode error: %s", err)
+	}
+
+	if d.Algorithm != "crypt" || d.Salt != "ab" || d.Hash != "abOV.DfJmdnYw" {
+		t.Fatalf("Decoded hash %+v does not match.", d)
+	}
+}
+
+func TestCryptDecodeSaltComponent(t *testing.T) {
+	_, err := NewCryptPasswordHasher().Decode("crypt$ab$abOV.DfJmdnYw")
+
+	if !errors.Is(err, ErrHashComponentMismatch) {
+		t.Fatalf("Error %v is not %s.", err, ErrHashComponentMismatch)
+	}
+}
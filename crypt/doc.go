@@ -0,0 +1,4 @@
+// Package crypt implements a Django compatible crypt algorithm.
+//
+// It uses the traditional DES based crypt(3) algorithm, implemented in pure Go.
+package crypt
@@ -10,6 +10,14 @@
 //
 //    <algorithm>$<iterations>$<salt>$<hash>
 //
-// This library supports Argon2, BCrypt, PBKDF2, MD5 and SHA1 algorithms.
+// This library supports Argon2, BCrypt, Crypt, PBKDF2, Scrypt, MD5 and SHA1 algorithms.
+//
+// Errors about a component of an encoded password, including the errors
+// of the hasher packages such as bcrypt.ErrInvalidSalt, are wrapped in a *HashError.
+// Compare them with errors.Is, not with ==.
+//
+// New salts have 12 characters by default, as in Django before 3.2.
+// The profiles of Django 3.2 and later, see UseProfile, use 22 characters
+// salts, and then hashes with shorter salts need a rehash.
 //
 package unchained
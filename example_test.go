@@ -21,7 +21,7 @@ func ExampleCheckPassword() {
 }
 
 func ExampleMakePassword() {
-	hash, err := unchained.MakePassword("my-password", unchained.GetRandomString(12), "default")
+	hash, err := unchained.MakePassword("my-password", "", "default")
 
 	if err == nil {
 		fmt.Println(hash)
@@ -0,0 +1,287 @@
+package unchained
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/alexandrevicenzi/unchained/internal/entropy"
+)
+
+// PasswordHasher is the interface implemented by password hashers.
+//
+// Hashers registered with RegisterHasher are used by CheckPassword,
+// MakePassword, IdentifyHasher and IsHasherImplemented.
+type PasswordHasher interface {
+	// Algorithm returns the hasher identifier used in encoded passwords.
+	Algorithm() string
+	// Encode turns a plain-text password into a hash.
+	Encode(password, salt string) (string, error)
+	// Verify if a plain-text password matches the encoded digest.
+	Verify(password, encoded string) (bool, error)
+	// Decode splits the encoded digest into its components.
+	Decode(encoded string) (*DecodedHash, error)
+	// MustUpdate returns true if the encoded digest
+	// must be encoded again using the current settings.
+	MustUpdate(encoded string) bool
+}
+
+// HashIdentifier is implemented by hashers whose encoded passwords
+// do not start with the "<algorithm>$" prefix, such as unsalted MD5.
+type HashIdentifier interface {
+	// Identify returns true if encoded was produced by the hasher.
+	Identify(encoded string) bool
+}
+
+// Salter is implemented by hashers that require a specific salt format.
+type Salter interface {
+	// Salt returns a new random salt.
+	Salt() (string, error)
+}
+
+// generatorSalter is implemented by the built-in hashers,
+// whose salts can be created by any SaltGenerator.
+type generatorSalter interface {
+	// saltFrom returns a salt created by g, or by the hasher if g is nil,
+	// with length characters, or the length of the hasher if length is zero.
+	saltFrom(g SaltGenerator, length int) (string, error)
+}
+
+// paramsHasher is implemented by the built-in hashers
+// whose parameters can be set by the options of MakePassword.
+type paramsHasher interface {
+	// withParams returns a copy of the hasher with the parameters of o,
+	// or the hasher itself if o has no parameters for it.
+	withParams(o *options) PasswordHasher
+}
+
+// RuntimeHardener is implemented by hashers that can run the work missing
+// from encoded passwords that use weaker settings than the hasher.
+type RuntimeHardener interface {
+	// HardenRuntime pads the verification of encoded up to the hasher settings.
+	HardenRuntime(password, encoded string) error
+}
+
+// ContextHasher is implemented by hashers that can stop
+// encoding or verifying a password when a context is done.
+//
+// Other hashers keep running in the background when the context is done,
+// but MakePasswordContext and CheckPasswordContext do not wait for them.
+type ContextHasher interface {
+	// EncodeContext turns a plain-text password into a hash.
+	EncodeContext(ctx context.Context, password, salt string) (string, error)
+	// VerifyContext checks if a plain-text password matches the encoded digest.
+	VerifyContext(ctx context.Context, password, encoded string) (bool, error)
+}
+
+// DecodedHash holds the components of an encoded password.
+type DecodedHash struct {
+	// Algorithm identifier.
+	Algorithm string
+	// Algorithm variant, e.g. argon2i for Argon2.
+	Variant string
+	// Algorithm version, e.g. 19 for Argon2 or 2b for bcrypt.
+	Version string
+	// Salt used to encode the password.
+	Salt string
+	// Work factors, named as in Django, e.g. iterations for PBKDF2,
+	// work_factor for bcrypt, or memory_cost, time_cost and parallelism for Argon2.
+	Params map[string]int
+	// Hash as stored in the encoded password.
+	Hash string
+	// ID of the pepper key, empty if the password is not peppered.
+	PepperKey string
+}
+
+var (
+	hashersMu sync.RWMutex
+	hashers   = make(map[string]PasswordHasher)
+	// Algorithms in registration order, used to identify hashes deterministically.
+	algorithms []string
+)
+
+// RegisterHasher makes a hasher available to the package level functions.
+//
+// If a hasher with the same algorithm is already registered it is replaced,
+// which allows tuning the parameters of the built-in hashers.
+func RegisterHasher(h PasswordHasher) {
+	hashersMu.Lock()
+	defer hashersMu.Unlock()
+
+	algorithm := h.Algorithm()
+
+	if _, ok := hashers[algorithm]; !ok {
+		algorithms = append(algorithms, algorithm)
+	}
+
+	hashers[algorithm] = h
+}
+
+// LookupHasher returns the registered hasher for the algorithm.
+//
+// If algorithm is "default", the hasher of DefaultHasher is returned.
+func LookupHasher(algorithm string) (PasswordHasher, error) {
+	if algorithm == "default" {
+		algorithm = DefaultHasher
+	}
+
+	hashersMu.RLock()
+	h, ok := hashers[algorithm]
+	hashersMu.RUnlock()
+
+	if ok {
+		return h, nil
+	}
+
+	if IsValidHasher(algorithm) {
+		return nil, ErrHasherNotImplemented
+	}
+
+	return nil, ErrInvalidHasher
+}
+
+// registeredHashers returns the registered hashers in registration order.
+func registeredHashers() []PasswordHasher {
+	hashersMu.RLock()
+	defer hashersMu.RUnlock()
+
+	list := make([]PasswordHasher, len(algorithms))
+
+	for i, algorithm := range algorithms {
+		list[i] = hashers[algorithm]
+	}
+
+	return list
+}
+
+func isRegistered(algorithm string) bool {
+	hashersMu.RLock()
+	_, ok := hashers[algorithm]
+	hashersMu.RUnlock()
+	return ok
+}
+
+// newSalt returns a random salt suitable for the hasher.
+//
+// The salt is created by the salt generator of o, or by the hasher
+// if there is none, unless the hasher requires a specific salt format.
+func newSalt(h PasswordHasher, o *options) (string, error) {
+	length := o.saltLength
+
+	if length <= 0 && o.saltEntropy > 0 {
+		length = entropy.Length(o.saltEntropy)
+	}
+
+	if s, ok := h.(generatorSalter); ok {
+		return s.saltFrom(o.saltGenerator, length)
+	}
+
+	if s, ok := h.(Salter); ok {
+		return s.Salt()
+	}
+
+	return randomSalt(o.saltGenerator, length, DefaultSaltEntropy)
+}
+
+// randomSalt returns a salt created by g with length characters,
+// or with enough characters for bits of entropy if length is zero.
+func randomSalt(g SaltGenerator, length, bits int) (string, error) {
+	if length <= 0 {
+		length = entropy.Length(bits)
+	}
+
+	return orDefault(g).RandomString(length)
+}
+
+// encode turns a plain-text password into a hash,
+// or returns ctx.Err() if ctx is done first.
+func encode(ctx context.Context, h PasswordHasher, password, salt string) (string, error) {
+	if c, ok := h.(ContextHasher); ok {
+		return c.EncodeContext(ctx, password, salt)
+	}
+
+	var encoded string
+
+	err := wait(ctx, func() (err error) {
+		encoded, err = h.Encode(password, salt)
+		return err
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	return encoded, nil
+}
+
+// verify checks if a plain-text password matches the encoded digest,
+// or returns ctx.Err() if ctx is done first.
+func verify(ctx context.Context, h PasswordHasher, password, encoded string) (bool, error) {
+	if c, ok := h.(ContextHasher); ok {
+		return c.VerifyContext(ctx, password, encoded)
+	}
+
+	var valid bool
+
+	err := wait(ctx, func() (err error) {
+		valid, err = h.Verify(password, encoded)
+		return err
+	})
+
+	if err != nil {
+		return false, err
+	}
+
+	return valid, nil
+}
+
+// backgroundKey is the context key of the sync.WaitGroup that counts
+// the functions left running in the background by wait, see options.run.
+type backgroundKey struct{}
+
+// wait runs f and returns its error, or ctx.Err() if ctx is done first.
+// In that case f keeps running in the background and its result is discarded.
+func wait(ctx context.Context, f func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if ctx.Done() == nil {
+		return f()
+	}
+
+	done := make(chan error, 1)
+	background, _ := ctx.Value(backgroundKey{}).(*sync.WaitGroup)
+
+	if background != nil {
+		background.Add(1)
+	}
+
+	go func() {
+		err := f()
+
+		if background != nil {
+			background.Done()
+		}
+
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// identifyHasher returns the algorithm of the hasher used in the encoded password.
+func identifyHasher(encoded string, list []PasswordHasher) string {
+	for _, h := range list {
+		if i, ok := h.(HashIdentifier); ok && i.Identify(encoded) {
+			return h.Algorithm()
+		}
+	}
+
+	return strings.SplitN(encoded, "$", 2)[0]
+}
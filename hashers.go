@@ -0,0 +1,498 @@
+package unchained
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/alexandrevicenzi/unchained/argon2"
+	"github.com/alexandrevicenzi/unchained/bcrypt"
+	"github.com/alexandrevicenzi/unchained/crypt"
+	"github.com/alexandrevicenzi/unchained/md5"
+	"github.com/alexandrevicenzi/unchained/pbkdf2"
+	"github.com/alexandrevicenzi/unchained/scrypt"
+	"github.com/alexandrevicenzi/unchained/sha1"
+)
+
+func init() {
+	RegisterHasher(Argon2(argon2.NewArgon2Hasher()))
+	RegisterHasher(BCrypt(bcrypt.NewBCryptHasher()))
+	RegisterHasher(BCrypt(bcrypt.NewBCryptSHA256Hasher()))
+	RegisterHasher(Crypt(crypt.NewCryptPasswordHasher()))
+	RegisterHasher(MD5(md5.NewMD5PasswordHasher()))
+	RegisterHasher(PBKDF2(pbkdf2.NewPBKDF2SHA1Hasher()))
+	RegisterHasher(PBKDF2(pbkdf2.NewPBKDF2SHA256Hasher()))
+	RegisterHasher(Scrypt(scrypt.NewScryptPasswordHasher()))
+	RegisterHasher(SHA1(sha1.NewSHA1PasswordHasher()))
+	RegisterHasher(UnsaltedMD5(md5.NewUnsaltedMD5PasswordHasher()))
+	RegisterHasher(SHA1(sha1.NewUnsaltedSHA1PasswordHasher()))
+}
+
+type argon2Hasher struct {
+	h *argon2.Argon2Hasher
+}
+
+// Argon2 returns a PasswordHasher backed by an argon2.Argon2Hasher.
+func Argon2(h *argon2.Argon2Hasher) PasswordHasher {
+	return &argon2Hasher{h}
+}
+
+func (a *argon2Hasher) Algorithm() string {
+	return a.h.Algorithm
+}
+
+func (a *argon2Hasher) Encode(password, salt string) (string, error) {
+	return a.h.Encode(password, salt)
+}
+
+func (a *argon2Hasher) Verify(password, encoded string) (bool, error) {
+	return a.h.Verify(password, encoded)
+}
+
+func (a *argon2Hasher) Decode(encoded string) (*DecodedHash, error) {
+	d, err := a.h.Decode(encoded)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &DecodedHash{
+		Algorithm: d.Algorithm,
+		Variant:   d.Variant,
+		Version:   strconv.Itoa(d.Version),
+		Salt:      d.Salt,
+		Params: map[string]int{
+			"memory_cost": int(d.Memory),
+			"time_cost":   int(d.Time),
+			"parallelism": int(d.Threads),
+		},
+		Hash: d.Hash,
+	}, nil
+}
+
+func (a *argon2Hasher) MustUpdate(encoded string) bool {
+	return a.h.MustUpdate(encoded)
+}
+
+func (a *argon2Hasher) Salt() (string, error) {
+	return a.saltFrom(nil, 0)
+}
+
+func (a *argon2Hasher) saltFrom(g SaltGenerator, length int) (string, error) {
+	return randomSalt(g, length, a.h.SaltEntropy)
+}
+
+func (a *argon2Hasher) withParams(o *options) PasswordHasher {
+	if o.argon2Time == 0 && o.argon2Memory == 0 && o.argon2Threads == 0 {
+		return a
+	}
+
+	h := *a.h
+
+	if o.argon2Time != 0 {
+		h.Time = o.argon2Time
+	}
+
+	if o.argon2Memory != 0 {
+		h.Memory = o.argon2Memory
+	}
+
+	if o.argon2Threads != 0 {
+		h.Threads = o.argon2Threads
+	}
+
+	return &argon2Hasher{&h}
+}
+
+func (a *argon2Hasher) memoryCost() int {
+	return int(a.h.Memory)
+}
+
+func (a *argon2Hasher) HardenRuntime(password, encoded string) error {
+	return a.h.HardenRuntime(password, encoded)
+}
+
+type bcryptHasher struct {
+	h *bcrypt.BCryptHasher
+}
+
+// BCrypt returns a PasswordHasher backed by a bcrypt.BCryptHasher.
+func BCrypt(h *bcrypt.BCryptHasher) PasswordHasher {
+	return &bcryptHasher{h}
+}
+
+func (b *bcryptHasher) Algorithm() string {
+	return b.h.Algorithm
+}
+
+func (b *bcryptHasher) Encode(password, salt string) (string, error) {
+	return b.h.Encode(password, salt)
+}
+
+func (b *bcryptHasher) Verify(password, encoded string) (bool, error) {
+	return b.h.Verify(password, encoded)
+}
+
+func (b *bcryptHasher) Decode(encoded string) (*DecodedHash, error) {
+	d, err := b.h.Decode(encoded)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &DecodedHash{
+		Algorithm: d.Algorithm,
+		Version:   d.Version,
+		Salt:      d.Salt,
+		Params:    map[string]int{"work_factor": d.Cost},
+		Hash:      d.Hash,
+	}, nil
+}
+
+func (b *bcryptHasher) MustUpdate(encoded string) bool {
+	return b.h.MustUpdate(encoded)
+}
+
+func (b *bcryptHasher) withParams(o *options) PasswordHasher {
+	if o.cost == 0 {
+		return b
+	}
+
+	h := *b.h
+	h.Cost = o.cost
+	return &bcryptHasher{&h}
+}
+
+func (b *bcryptHasher) HardenRuntime(password, encoded string) error {
+	return b.h.HardenRuntime(password, encoded)
+}
+
+func (b *bcryptHasher) Salt() (string, error) {
+	return bcrypt.NewSalt()
+}
+
+// Any 22 characters of the bcrypt alphabet are a valid salt.
+func (b *bcryptHasher) saltFrom(g SaltGenerator, length int) (string, error) {
+	if g == nil {
+		return bcrypt.NewSalt()
+	}
+
+	return g.RandomString(22)
+}
+
+type cryptHasher struct {
+	h *crypt.CryptPasswordHasher
+}
+
+// Crypt returns a PasswordHasher backed by a crypt.CryptPasswordHasher.
+func Crypt(h *crypt.CryptPasswordHasher) PasswordHasher {
+	return &cryptHasher{h}
+}
+
+func (c *cryptHasher) Algorithm() string {
+	return c.h.Algorithm
+}
+
+func (c *cryptHasher) Encode(password, salt string) (string, error) {
+	return c.h.Encode(password, salt)
+}
+
+func (c *cryptHasher) Verify(password, encoded string) (bool, error) {
+	return c.h.Verify(password, encoded)
+}
+
+func (c *cryptHasher) Decode(encoded string) (*DecodedHash, error) {
+	d, err := c.h.Decode(encoded)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &DecodedHash{
+		Algorithm: d.Algorithm,
+		Salt:      d.Salt,
+		Hash:      d.Hash,
+	}, nil
+}
+
+func (c *cryptHasher) MustUpdate(encoded string) bool {
+	return false
+}
+
+func (c *cryptHasher) Salt() (string, error) {
+	return c.saltFrom(nil, 0)
+}
+
+// Crypt salts always have 2 characters.
+func (c *cryptHasher) saltFrom(g SaltGenerator, length int) (string, error) {
+	return orDefault(g).RandomString(2)
+}
+
+type pbkdf2Hasher struct {
+	h *pbkdf2.PBKDF2Hasher
+}
+
+// PBKDF2 returns a PasswordHasher backed by a pbkdf2.PBKDF2Hasher.
+//
+// Passwords are encoded with the number of iterations set in the hasher.
+func PBKDF2(h *pbkdf2.PBKDF2Hasher) PasswordHasher {
+	return &pbkdf2Hasher{h}
+}
+
+func (p *pbkdf2Hasher) Algorithm() string {
+	return p.h.Algorithm
+}
+
+func (p *pbkdf2Hasher) Encode(password, salt string) (string, error) {
+	return p.h.Encode(password, salt, 0)
+}
+
+func (p *pbkdf2Hasher) Verify(password, encoded string) (bool, error) {
+	return p.h.Verify(password, encoded)
+}
+
+func (p *pbkdf2Hasher) EncodeContext(ctx context.Context, password, salt string) (string, error) {
+	return p.h.EncodeContext(ctx, password, salt, 0)
+}
+
+func (p *pbkdf2Hasher) VerifyContext(ctx context.Context, password, encoded string) (bool, error) {
+	return p.h.VerifyContext(ctx, password, encoded)
+}
+
+func (p *pbkdf2Hasher) withParams(o *options) PasswordHasher {
+	if o.iterations == 0 {
+		return p
+	}
+
+	h := *p.h
+	h.Iterations = o.iterations
+	return &pbkdf2Hasher{&h}
+}
+
+func (p *pbkdf2Hasher) Decode(encoded string) (*DecodedHash, error) {
+	d, err := p.h.Decode(encoded)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &DecodedHash{
+		Algorithm: d.Algorithm,
+		Salt:      d.Salt,
+		Params:    map[string]int{"iterations": d.Iterations},
+		Hash:      d.Hash,
+	}, nil
+}
+
+func (p *pbkdf2Hasher) MustUpdate(encoded string) bool {
+	return p.h.MustUpdate(encoded)
+}
+
+func (p *pbkdf2Hasher) Salt() (string, error) {
+	return p.saltFrom(nil, 0)
+}
+
+func (p *pbkdf2Hasher) saltFrom(g SaltGenerator, length int) (string, error) {
+	return randomSalt(g, length, p.h.SaltEntropy)
+}
+
+func (p *pbkdf2Hasher) HardenRuntime(password, encoded string) error {
+	return p.h.HardenRuntime(password, encoded)
+}
+
+type scryptHasher struct {
+	h *scrypt.ScryptPasswordHasher
+}
+
+// Scrypt returns a PasswordHasher backed by a scrypt.ScryptPasswordHasher.
+func Scrypt(h *scrypt.ScryptPasswordHasher) PasswordHasher {
+	return &scryptHasher{h}
+}
+
+func (s *scryptHasher) Algorithm() string {
+	return s.h.Algorithm
+}
+
+func (s *scryptHasher) Encode(password, salt string) (string, error) {
+	return s.h.Encode(password, salt)
+}
+
+func (s *scryptHasher) Verify(password, encoded string) (bool, error) {
+	return s.h.Verify(password, encoded)
+}
+
+func (s *scryptHasher) Decode(encoded string) (*DecodedHash, error) {
+	d, err := s.h.Decode(encoded)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &DecodedHash{
+		Algorithm: d.Algorithm,
+		Salt:      d.Salt,
+		Params: map[string]int{
+			"work_factor": d.WorkFactor,
+			"block_size":  d.BlockSize,
+			"parallelism": d.Parallelism,
+		},
+		Hash: d.Hash,
+	}, nil
+}
+
+func (s *scryptHasher) MustUpdate(encoded string) bool {
+	return s.h.MustUpdate(encoded)
+}
+
+func (s *scryptHasher) Salt() (string, error) {
+	return s.saltFrom(nil, 0)
+}
+
+func (s *scryptHasher) saltFrom(g SaltGenerator, length int) (string, error) {
+	return randomSalt(g, length, s.h.SaltEntropy)
+}
+
+type md5Hasher struct {
+	h *md5.MD5PasswordHasher
+}
+
+// MD5 returns a PasswordHasher backed by a md5.MD5PasswordHasher.
+func MD5(h *md5.MD5PasswordHasher) PasswordHasher {
+	return &md5Hasher{h}
+}
+
+func (m *md5Hasher) Algorithm() string {
+	return m.h.Algorithm
+}
+
+func (m *md5Hasher) Encode(password, salt string) (string, error) {
+	return m.h.Encode(password, salt)
+}
+
+func (m *md5Hasher) Verify(password, encoded string) (bool, error) {
+	return m.h.Verify(password, encoded)
+}
+
+func (m *md5Hasher) Decode(encoded string) (*DecodedHash, error) {
+	d, err := m.h.Decode(encoded)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &DecodedHash{
+		Algorithm: d.Algorithm,
+		Salt:      d.Salt,
+		Hash:      d.Hash,
+	}, nil
+}
+
+func (m *md5Hasher) MustUpdate(encoded string) bool {
+	return m.h.MustUpdate(encoded)
+}
+
+func (m *md5Hasher) Salt() (string, error) {
+	return m.saltFrom(nil, 0)
+}
+
+func (m *md5Hasher) saltFrom(g SaltGenerator, length int) (string, error) {
+	return randomSalt(g, length, m.h.SaltEntropy)
+}
+
+type unsaltedMD5Hasher struct {
+	h *md5.UnsaltedMD5PasswordHasher
+}
+
+// UnsaltedMD5 returns a PasswordHasher backed by a md5.UnsaltedMD5PasswordHasher.
+//
+// The salt passed to Encode is ignored.
+func UnsaltedMD5(h *md5.UnsaltedMD5PasswordHasher) PasswordHasher {
+	return &unsaltedMD5Hasher{h}
+}
+
+func (m *unsaltedMD5Hasher) Algorithm() string {
+	return m.h.Algorithm
+}
+
+func (m *unsaltedMD5Hasher) Encode(password, salt string) (string, error) {
+	return m.h.Encode(password)
+}
+
+func (m *unsaltedMD5Hasher) Verify(password, encoded string) (bool, error) {
+	return m.h.Verify(password, encoded)
+}
+
+func (m *unsaltedMD5Hasher) Decode(encoded string) (*DecodedHash, error) {
+	d, err := m.h.Decode(encoded)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &DecodedHash{
+		Algorithm: d.Algorithm,
+		Salt:      d.Salt,
+		Hash:      d.Hash,
+	}, nil
+}
+
+func (m *unsaltedMD5Hasher) MustUpdate(encoded string) bool {
+	return false
+}
+
+func (m *unsaltedMD5Hasher) Identify(encoded string) bool {
+	size := len(encoded)
+	return (size == 32 && !strings.Contains(encoded, "$")) ||
+		(size == 37 && strings.HasPrefix(encoded, "md5$$"))
+}
+
+type sha1Hasher struct {
+	h *sha1.SHA1PasswordHasher
+}
+
+// SHA1 returns a PasswordHasher backed by a sha1.SHA1PasswordHasher.
+func SHA1(h *sha1.SHA1PasswordHasher) PasswordHasher {
+	return &sha1Hasher{h}
+}
+
+func (s *sha1Hasher) Algorithm() string {
+	return s.h.Algorithm
+}
+
+func (s *sha1Hasher) Encode(password, salt string) (string, error) {
+	return s.h.Encode(password, salt)
+}
+
+func (s *sha1Hasher) Verify(password, encoded string) (bool, error) {
+	return s.h.Verify(password, encoded)
+}
+
+func (s *sha1Hasher) Decode(encoded string) (*DecodedHash, error) {
+	d, err := s.h.Decode(encoded)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &DecodedHash{
+		Algorithm: d.Algorithm,
+		Salt:      d.Salt,
+		Hash:      d.Hash,
+	}, nil
+}
+
+func (s *sha1Hasher) MustUpdate(encoded string) bool {
+	return s.h.MustUpdate(encoded)
+}
+
+func (s *sha1Hasher) Salt() (string, error) {
+	return s.saltFrom(nil, 0)
+}
+
+func (s *sha1Hasher) saltFrom(g SaltGenerator, length int) (string, error) {
+	return randomSalt(g, length, s.h.SaltEntropy)
+}
+
+func (s *sha1Hasher) Identify(encoded string) bool {
+	return !s.h.Salted && len(encoded) == 46 && strings.HasPrefix(encoded, "sha1$$")
+}
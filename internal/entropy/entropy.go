@@ -0,0 +1,39 @@
+// Package entropy sizes salts from a number of bits of entropy, like Django.
+//
+// Salts are assumed to be made of the 62 letters and digits
+// of Django's RANDOM_STRING_CHARS.
+package entropy
+
+import "math"
+
+// Default is the salt entropy in bits of the 12 characters salts
+// used before Django 3.2.
+const Default = 71
+
+// bitsPerChar is the entropy of a character of RANDOM_STRING_CHARS.
+var bitsPerChar = math.Log2(62)
+
+// Of returns the entropy of salt in bits.
+func Of(salt string) float64 {
+	return float64(len(salt)) * bitsPerChar
+}
+
+// Length returns the number of characters needed for bits of entropy,
+// or for Default if bits is zero or negative.
+func Length(bits int) int {
+	if bits <= 0 {
+		bits = Default
+	}
+
+	return int(math.Ceil(float64(bits) / bitsPerChar))
+}
+
+// Enough returns true if salt has at least bits of entropy,
+// or Default if bits is zero or negative.
+func Enough(salt string, bits int) bool {
+	if bits <= 0 {
+		bits = Default
+	}
+
+	return Of(salt) >= float64(bits)
+}
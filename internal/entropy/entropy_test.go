@@ -0,0 +1,33 @@
+package entropy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLength(t *testing.T) {
+	for bits, length := range map[int]int{
+		0:   12,
+		71:  12,
+		128: 22,
+		256: 43,
+	} {
+		if l := Length(bits); l != length {
+			t.Fatalf("Length of %d bits is %d, expected %d.", bits, l, length)
+		}
+	}
+}
+
+func TestEnough(t *testing.T) {
+	if Enough(strings.Repeat("a", 21), 128) {
+		t.Fatal("21 characters have 128 bits of entropy.")
+	}
+
+	if !Enough(strings.Repeat("a", 12), 0) {
+		t.Fatal("12 characters do not have the default entropy.")
+	}
+
+	if !Enough(strings.Repeat("a", 12), 71) {
+		t.Fatal("12 characters do not have 71 bits of entropy.")
+	}
+}
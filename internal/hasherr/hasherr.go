@@ -0,0 +1,69 @@
+// Package hasherr defines the error categories shared by the hasher packages.
+//
+// The categories and HashError are exported by package unchained.
+package hasherr
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Error categories.
+var (
+	ErrMalformedHash        = errors.New("unchained: malformed hash")
+	ErrUnsupportedAlgorithm = errors.New("unchained: unsupported algorithm")
+	ErrUnsupportedVersion   = errors.New("unchained: unsupported version")
+	ErrInvalidParameters    = errors.New("unchained: invalid parameters")
+	ErrInvalidSalt          = errors.New("unchained: invalid salt")
+	ErrLimitExceeded        = errors.New("unchained: parameters exceed limits")
+	ErrPasswordTooLong      = errors.New("unchained: password too long")
+)
+
+// categoryError is an error that belongs to a category.
+type categoryError struct {
+	msg      string
+	category error
+}
+
+func (e *categoryError) Error() string {
+	return e.msg
+}
+
+func (e *categoryError) Is(target error) bool {
+	return target == e.category
+}
+
+// New returns an error with the given text that matches category with errors.Is.
+func New(category error, text string) error {
+	return &categoryError{text, category}
+}
+
+// HashError records the algorithm and the component
+// of an encoded password that caused an error.
+type HashError struct {
+	// Algorithm identifier, e.g. pbkdf2_sha256.
+	Algorithm string
+	// Component of the encoded password, named as in Django,
+	// e.g. salt or iterations. Empty if the error is not
+	// specific to one component.
+	Component string
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *HashError) Error() string {
+	if e.Component == "" {
+		return fmt.Sprintf("%s: %s", e.Algorithm, e.Err)
+	}
+
+	return fmt.Sprintf("%s %s: %s", e.Algorithm, e.Component, e.Err)
+}
+
+func (e *HashError) Unwrap() error {
+	return e.Err
+}
+
+// Wrap returns a HashError for the algorithm and component.
+func Wrap(algorithm, component string, err error) error {
+	return &HashError{Algorithm: algorithm, Component: component, Err: err}
+}
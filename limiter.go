@@ -0,0 +1,190 @@
+package unchained
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrOverloaded is returned by a Limiter when its queue is full.
+var ErrOverloaded = errors.New("unchained: too many concurrent password operations")
+
+// Limiter bounds the password operations that run at the same time,
+// and the Argon2 memory they use, see WithLimiter.
+//
+// Callers over the limits wait in a queue, in order of arrival.
+// When the queue is full, ErrOverloaded is returned immediately,
+// so a burst of logins degrades gracefully instead of exhausting
+// memory and CPU.
+type Limiter struct {
+	maxConcurrent int
+	maxMemory     int
+	maxQueue      int
+
+	mu      sync.Mutex
+	running int
+	memory  int
+	waiters []*waiter
+}
+
+type waiter struct {
+	memory int
+	ready  chan struct{}
+}
+
+// NewLimiter returns a Limiter that runs up to maxConcurrent operations,
+// using up to maxMemory KiB of Argon2 memory in total, and queues up to
+// maxQueue callers. Zero maxConcurrent or maxMemory means no limit,
+// zero maxQueue means callers over the limits never wait.
+//
+// An operation that needs more than maxMemory is counted as maxMemory,
+// so it runs without other operations that use memory.
+func NewLimiter(maxConcurrent, maxMemory, maxQueue int) *Limiter {
+	return &Limiter{
+		maxConcurrent: maxConcurrent,
+		maxMemory:     maxMemory,
+		maxQueue:      maxQueue,
+	}
+}
+
+// Do runs f once the limits allow an operation that uses memory KiB.
+//
+// It returns ErrOverloaded if the queue is full, or ctx.Err() if ctx
+// is done before f returns. The operation counts against the limits
+// until f returns, even if Do has already returned.
+func (l *Limiter) Do(ctx context.Context, memory int, f func() error) error {
+	if l.maxMemory > 0 && memory > l.maxMemory {
+		memory = l.maxMemory
+	}
+
+	if err := l.acquire(ctx, memory); err != nil {
+		return err
+	}
+
+	if ctx.Done() == nil {
+		defer l.release(memory)
+		return f()
+	}
+
+	done := make(chan error, 1)
+
+	go func() {
+		err := f()
+		l.release(memory)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// fits returns true if an operation that uses memory can start.
+// It must be called with l.mu held.
+func (l *Limiter) fits(memory int) bool {
+	if l.maxConcurrent > 0 && l.running >= l.maxConcurrent {
+		return false
+	}
+
+	return l.maxMemory <= 0 || l.memory+memory <= l.maxMemory
+}
+
+func (l *Limiter) acquire(ctx context.Context, memory int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+
+	if len(l.waiters) == 0 && l.fits(memory) {
+		l.running++
+		l.memory += memory
+		l.mu.Unlock()
+		return nil
+	}
+
+	if len(l.waiters) >= l.maxQueue {
+		l.mu.Unlock()
+		return ErrOverloaded
+	}
+
+	w := &waiter{memory: memory, ready: make(chan struct{})}
+	l.waiters = append(l.waiters, w)
+	l.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+	}
+
+	l.mu.Lock()
+
+	select {
+	case <-w.ready:
+		// Started while ctx was done, give the slot back.
+		l.mu.Unlock()
+		l.release(memory)
+		return ctx.Err()
+	default:
+	}
+
+	for i, v := range l.waiters {
+		if v == w {
+			l.waiters = append(l.waiters[:i], l.waiters[i+1:]...)
+			break
+		}
+	}
+
+	// The removed waiter may have blocked the next ones.
+	l.start()
+	l.mu.Unlock()
+
+	return ctx.Err()
+}
+
+func (l *Limiter) release(memory int) {
+	l.mu.Lock()
+	l.running--
+	l.memory -= memory
+	l.start()
+	l.mu.Unlock()
+}
+
+// start starts the waiters that fit, in order of arrival.
+// It must be called with l.mu held.
+func (l *Limiter) start() {
+	for len(l.waiters) > 0 && l.fits(l.waiters[0].memory) {
+		w := l.waiters[0]
+		l.waiters[0] = nil
+		l.waiters = l.waiters[1:]
+		l.running++
+		l.memory += w.memory
+		close(w.ready)
+	}
+}
+
+// memoryCoster is implemented by hashers that allocate memory
+// proportional to their parameters, such as Argon2.
+type memoryCoster interface {
+	// memoryCost returns the memory in KiB used to encode a password.
+	memoryCost() int
+}
+
+// encodeMemory returns the memory in KiB used by h to encode a password.
+func encodeMemory(h PasswordHasher) int {
+	if m, ok := h.(memoryCoster); ok {
+		return m.memoryCost()
+	}
+
+	return 0
+}
+
+// verifyMemory returns the memory in KiB used to verify a password
+// encoded with the parameters of d.
+func verifyMemory(d *DecodedHash) int {
+	return d.Params["memory_cost"]
+}
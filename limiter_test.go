@@ -0,0 +1,298 @@
+package unchained
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alexandrevicenzi/unchained/argon2"
+)
+
+// waitQueued waits until n callers are queued in l.
+func waitQueued(t *testing.T, l *Limiter, n int) {
+	for i := 0; i < 1000; i++ {
+		l.mu.Lock()
+		queued := len(l.waiters)
+		l.mu.Unlock()
+
+		if queued == n {
+			return
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	t.Fatalf("Limiter does not have %d queued callers.", n)
+}
+
+// waitRunning waits until n operations run in l.
+func waitRunning(t *testing.T, l *Limiter, n int) {
+	for i := 0; i < 1000; i++ {
+		l.mu.Lock()
+		running := l.running
+		l.mu.Unlock()
+
+		if running == n {
+			return
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	t.Fatalf("Limiter does not run %d operations.", n)
+}
+
+// hold runs an operation that uses memory in l until release is closed.
+func hold(l *Limiter, memory int, release chan struct{}) chan error {
+	done := make(chan error, 1)
+
+	go func() {
+		done <- l.Do(context.Background(), memory, func() error {
+			<-release
+			return nil
+		})
+	}()
+
+	return done
+}
+
+func TestLimiterConcurrency(t *testing.T) {
+	l := NewLimiter(1, 0, 1)
+	release := make(chan struct{})
+	first := hold(l, 0, release)
+	waitRunning(t, l, 1)
+	second := hold(l, 0, release)
+	waitQueued(t, l, 1)
+
+	if err := l.Do(context.Background(), 0, func() error { return nil }); err != ErrOverloaded {
+		t.Fatalf("Error %v is not %s.", err, ErrOverloaded)
+	}
+
+	close(release)
+
+	for _, done := range []chan error{first, second} {
+		if err := <-done; err != nil {
+			t.Fatalf("Do error: %s", err)
+		}
+	}
+
+	waitRunning(t, l, 0)
+}
+
+func TestLimiterMemory(t *testing.T) {
+	l := NewLimiter(0, 100, 1)
+	release := make(chan struct{})
+	first := hold(l, 60, release)
+	waitRunning(t, l, 1)
+
+	// Operations without memory are not limited.
+	if err := l.Do(context.Background(), 0, func() error { return nil }); err != nil {
+		t.Fatalf("Do error: %s", err)
+	}
+
+	second := hold(l, 60, release)
+	waitQueued(t, l, 1)
+
+	if err := l.Do(context.Background(), 10, func() error { return nil }); err != ErrOverloaded {
+		t.Fatalf("Error %v is not %s.", err, ErrOverloaded)
+	}
+
+	close(release)
+
+	for _, done := range []chan error{first, second} {
+		if err := <-done; err != nil {
+			t.Fatalf("Do error: %s", err)
+		}
+	}
+
+	// Operations over the memory limit run alone.
+	if err := l.Do(context.Background(), 1000, func() error { return nil }); err != nil {
+		t.Fatalf("Do error: %s", err)
+	}
+}
+
+func TestLimiterContext(t *testing.T) {
+	l := NewLimiter(1, 0, 1)
+	release := make(chan struct{})
+	defer close(release)
+	hold(l, 0, release)
+	waitRunning(t, l, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := l.Do(ctx, 0, func() error { return nil }); err != context.DeadlineExceeded {
+		t.Fatalf("Error %v is not %s.", err, context.DeadlineExceeded)
+	}
+
+	waitQueued(t, l, 0)
+}
+
+func TestCheckPasswordWithLimiter(t *testing.T) {
+	h := &blockingHasher{release: make(chan struct{})}
+	c, err := NewContext(h)
+
+	if err != nil {
+		t.Fatalf("NewContext error: %s", err)
+	}
+
+	l := NewLimiter(1, 0, 0)
+	done := make(chan error, 1)
+
+	go func() {
+		_, err := c.CheckPassword("admin", "reverse$salt$nimda", WithLimiter(l))
+		done <- err
+	}()
+
+	waitRunning(t, l, 1)
+
+	if _, err := c.CheckPassword("admin", "reverse$salt$nimda", WithLimiter(l)); err != ErrOverloaded {
+		t.Fatalf("Error %v is not %s.", err, ErrOverloaded)
+	}
+
+	if _, err := c.MakePassword("admin", "salt", "default", WithLimiter(l)); err != ErrOverloaded {
+		t.Fatalf("Error %v is not %s.", err, ErrOverloaded)
+	}
+
+	close(h.release)
+
+	if err := <-done; err != nil {
+		t.Fatalf("CheckPassword error: %s", err)
+	}
+
+	if valid, err := c.CheckPassword("admin", "reverse$salt$nimda", WithLimiter(l)); !valid || err != nil {
+		t.Fatalf("Password should be valid, error: %v", err)
+	}
+}
+
+func TestLimiterArgon2Memory(t *testing.T) {
+	h := argon2.NewArgon2Hasher()
+	h.Memory = 64
+	h.Threads = 1
+	a := Argon2(h)
+
+	if m := encodeMemory(a); m != 64 {
+		t.Fatalf("Encode memory %d is not 64.", m)
+	}
+
+	encoded, err := a.Encode("admin", "seasaltseasalt")
+
+	if err != nil {
+		t.Fatalf("Encode error: %s", err)
+	}
+
+	d, err := a.Decode(encoded)
+
+	if err != nil {
+		t.Fatalf("Decode error: %s", err)
+	}
+
+	if m := verifyMemory(d); m != 64 {
+		t.Fatalf("Verify memory %d is not 64.", m)
+	}
+
+	c, err := NewContext(a)
+
+	if err != nil {
+		t.Fatalf("NewContext error: %s", err)
+	}
+
+	// The password needs more memory than the limit, it runs alone.
+	if valid, err := c.CheckPassword("admin", encoded, WithLimiter(NewLimiter(1, 32, 0))); !valid || err != nil {
+		t.Fatalf("Password should be valid, error: %v", err)
+	}
+}
+
+// interruptibleHasher blocks until the context is done or release is closed.
+type interruptibleHasher struct {
+	reverseHasher
+	release chan struct{}
+}
+
+func (h *interruptibleHasher) EncodeContext(ctx context.Context, password, salt string) (string, error) {
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case <-h.release:
+		return h.Encode(password, salt)
+	}
+}
+
+func (h *interruptibleHasher) VerifyContext(ctx context.Context, password, encoded string) (bool, error) {
+	select {
+	case <-ctx.Done():
+		return false, ctx.Err()
+	case <-h.release:
+		return h.Verify(password, encoded)
+	}
+}
+
+// makePasswordCancel runs MakePasswordContext with h within l,
+// and cancels it once it runs.
+func makePasswordCancel(t *testing.T, h PasswordHasher, l *Limiter) {
+	c, err := NewContext(h)
+
+	if err != nil {
+		t.Fatalf("NewContext error: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+
+	go func() {
+		_, err := c.MakePasswordContext(ctx, "admin", "salt", "default", WithLimiter(l))
+		done <- err
+	}()
+
+	waitRunning(t, l, 1)
+	cancel()
+
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("Error %v is not %s.", err, context.Canceled)
+	}
+}
+
+func TestLimiterContextUninterruptible(t *testing.T) {
+	pepper := func(h PasswordHasher) PasswordHasher {
+		p, err := Pepper(h, Keyring{Current: "k1", Keys: map[string][]byte{"k1": []byte("secret")}})
+
+		if err != nil {
+			t.Fatalf("Pepper error: %s", err)
+		}
+
+		return p
+	}
+
+	// The pepper stops when the context is done, but not the hasher it wraps.
+	for _, wrap := range []func(PasswordHasher) PasswordHasher{
+		func(h PasswordHasher) PasswordHasher { return h },
+		pepper,
+	} {
+		h := &blockingHasher{release: make(chan struct{})}
+		l := NewLimiter(1, 0, 1)
+		makePasswordCancel(t, wrap(h), l)
+
+		// The hasher keeps running and holds its slot.
+		l.mu.Lock()
+		running := l.running
+		l.mu.Unlock()
+
+		if running != 1 {
+			t.Fatalf("Limiter runs %d operations, not 1.", running)
+		}
+
+		close(h.release)
+		waitRunning(t, l, 0)
+	}
+}
+
+func TestLimiterContextInterruptible(t *testing.T) {
+	h := &interruptibleHasher{release: make(chan struct{})}
+	defer close(h.release)
+
+	l := NewLimiter(1, 0, 1)
+	makePasswordCancel(t, h, l)
+
+	// The hasher stops with the context and gives its slot back.
+	waitRunning(t, l, 0)
+}
@@ -0,0 +1,61 @@
+package unchained
+
+import "github.com/alexandrevicenzi/unchained/internal/hasherr"
+
+// Limits restricts the parameters read from encoded passwords
+// before they are verified, so a crafted or corrupted hash cannot
+// exhaust memory or CPU.
+//
+// Zero means no limit. Hashers also apply their own limits.
+type Limits struct {
+	// Maximum memory (KiB) of Argon2 and scrypt.
+	MaxMemory int
+	// Maximum Argon2 time cost.
+	MaxTime int
+	// Maximum Argon2 and scrypt parallelism.
+	MaxThreads int
+	// Maximum PBKDF2 iterations.
+	MaxIterations int
+	// Maximum bcrypt cost.
+	MaxCost int
+	// Maximum length of the hash as stored in the encoded password.
+	MaxHashLength int
+}
+
+// check returns an error if the decoded parameters exceed the limits.
+func (l *Limits) check(d *DecodedHash) error {
+	memory, cost := d.Params["memory_cost"], 0
+
+	// Scrypt uses 128 * N * r bytes, bcrypt's work factor is its cost.
+	if r, ok := d.Params["block_size"]; ok {
+		memory = d.Params["work_factor"] / 8
+
+		if r > 0 && memory > int(^uint(0)>>1)/r {
+			memory = int(^uint(0) >> 1)
+		} else {
+			memory *= r
+		}
+	} else {
+		cost = d.Params["work_factor"]
+	}
+
+	for _, c := range []struct {
+		component  string
+		value, max int
+	}{
+		{"memory_cost", memory, l.MaxMemory},
+		{"time_cost", d.Params["time_cost"], l.MaxTime},
+		{"parallelism", d.Params["parallelism"], l.MaxThreads},
+		{"iterations", d.Params["iterations"], l.MaxIterations},
+		{"work_factor", cost, l.MaxCost},
+		{"hash", len(d.Hash), l.MaxHashLength},
+	} {
+		if c.max > 0 && c.value > c.max {
+			return hasherr.Wrap(d.Algorithm, c.component, errLimitExceeded)
+		}
+	}
+
+	return nil
+}
+
+var errLimitExceeded = hasherr.New(ErrLimitExceeded, "unchained: parameters exceed limits")
@@ -3,33 +3,71 @@ package md5
 import (
 	"crypto/hmac"
 	"crypto/md5"
-	"errors"
 	"fmt"
 	"io"
 	"strings"
+
+	"github.com/alexandrevicenzi/unchained/internal/entropy"
+	"github.com/alexandrevicenzi/unchained/internal/hasherr"
 )
 
 // Errors returned by UnsaltedMD5PasswordHasher and/or MD5PasswordHasher.
+//
+// Errors about a component of an encoded password are wrapped
+// in an unchained.HashError, match them with errors.Is.
 var (
-	ErrHashComponentMismatch  = errors.New("unchained/md5: hashed password components mismatch")
-	ErrAlgorithmMismatch      = errors.New("unchained/md5: algorithm mismatch")
-	ErrSaltContainsDollarSing = errors.New("unchained/md5: salt contains dollar sign ($)")
-	ErrSaltIsEmpty            = errors.New("unchained/md5: salt is empty")
+	ErrHashComponentMismatch  = hasherr.New(hasherr.ErrMalformedHash, "unchained/md5: hashed password components mismatch")
+	ErrAlgorithmMismatch      = hasherr.New(hasherr.ErrUnsupportedAlgorithm, "unchained/md5: algorithm mismatch")
+	ErrSaltContainsDollarSing = hasherr.New(hasherr.ErrInvalidSalt, "unchained/md5: salt contains dollar sign ($)")
+	ErrSaltIsEmpty            = hasherr.New(hasherr.ErrInvalidSalt, "unchained/md5: salt is empty")
+	ErrPasswordTooLong        = hasherr.New(hasherr.ErrPasswordTooLong, "unchained/md5: password too long")
 )
 
+// DecodedHash holds the components of a MD5 encoded password.
+type DecodedHash struct {
+	// Algorithm identifier.
+	Algorithm string
+	// Salt used to encode the password.
+	Salt string
+	// Hex encoded hash.
+	Hash string
+}
+
 // UnsaltedMD5PasswordHasher implements a simple MD5 password hasher.
 type UnsaltedMD5PasswordHasher struct {
 	// Algorithm identifier.
 	Algorithm string
+	// Defines the maximum length of passwords in bytes, zero means no limit.
+	MaxPasswordLength int
 }
 
 // Encode turns a plain-text password into a hash.
 func (h *UnsaltedMD5PasswordHasher) Encode(password string) (string, error) {
+	if h.MaxPasswordLength > 0 && len(password) > h.MaxPasswordLength {
+		return "", ErrPasswordTooLong
+	}
+
 	hasher := md5.New()
 	io.WriteString(hasher, password)
 	return fmt.Sprintf("%x", hasher.Sum(nil)), nil
 }
 
+// Decode splits the encoded digest into its components.
+func (h *UnsaltedMD5PasswordHasher) Decode(encoded string) (*DecodedHash, error) {
+	if len(encoded) == 37 && strings.HasPrefix(encoded, "md5$$") {
+		encoded = encoded[5:]
+	}
+
+	if len(encoded) != 32 || strings.Contains(encoded, "$") {
+		return nil, ErrHashComponentMismatch
+	}
+
+	return &DecodedHash{
+		Algorithm: h.Algorithm,
+		Hash:      encoded,
+	}, nil
+}
+
 // Verify if a plain-text password matches the encoded digest.
 func (h *UnsaltedMD5PasswordHasher) Verify(password string, encoded string) (bool, error) {
 	if len(encoded) == 37 && strings.HasPrefix(encoded, "md5$$") {
@@ -49,16 +87,25 @@ func (h *UnsaltedMD5PasswordHasher) Verify(password string, encoded string) (boo
 type MD5PasswordHasher struct {
 	// Algorithm identifier.
 	Algorithm string
+	// Defines the maximum length of passwords in bytes, zero means no limit.
+	MaxPasswordLength int
+	// Defines the minimum entropy of salts in bits, zero means unchained.DefaultSaltEntropy.
+	// Encoded digests whose salt has less entropy must be updated.
+	SaltEntropy int
 }
 
 // Encode turns a plain-text password into a hash.
 func (h *MD5PasswordHasher) Encode(password string, salt string) (string, error) {
+	if h.MaxPasswordLength > 0 && len(password) > h.MaxPasswordLength {
+		return "", ErrPasswordTooLong
+	}
+
 	if len(salt) == 0 {
-		return "", ErrSaltIsEmpty
+		return "", hasherr.Wrap(h.Algorithm, "salt", ErrSaltIsEmpty)
 	}
 
 	if strings.Contains(salt, "$") {
-		return "", ErrSaltContainsDollarSing
+		return "", hasherr.Wrap(h.Algorithm, "salt", ErrSaltContainsDollarSing)
 	}
 
 	hasher := md5.New()
@@ -67,21 +114,36 @@ func (h *MD5PasswordHasher) Encode(password string, salt string) (string, error)
 	return fmt.Sprintf("%s$%s$%x", h.Algorithm, salt, hasher.Sum(nil)), nil
 }
 
-// Verify if a plain-text password matches the encoded digest.
-func (h *MD5PasswordHasher) Verify(password string, encoded string) (bool, error) {
+// Decode splits the encoded digest into its components.
+func (h *MD5PasswordHasher) Decode(encoded string) (*DecodedHash, error) {
 	s := strings.Split(encoded, "$")
 
 	if len(s) != 3 {
-		return false, ErrHashComponentMismatch
+		return nil, ErrHashComponentMismatch
 	}
 
-	algorithm, salt := s[0], s[1]
+	algorithm, salt, hash := s[0], s[1], s[2]
 
 	if algorithm != h.Algorithm {
-		return false, ErrAlgorithmMismatch
+		return nil, hasherr.Wrap(h.Algorithm, "algorithm", ErrAlgorithmMismatch)
 	}
 
-	newencoded, err := h.Encode(password, salt)
+	return &DecodedHash{
+		Algorithm: algorithm,
+		Salt:      salt,
+		Hash:      hash,
+	}, nil
+}
+
+// Verify if a plain-text password matches the encoded digest.
+func (h *MD5PasswordHasher) Verify(password string, encoded string) (bool, error) {
+	d, err := h.Decode(encoded)
+
+	if err != nil {
+		return false, err
+	}
+
+	newencoded, err := h.Encode(password, d.Salt)
 
 	if err != nil {
 		return false, err
@@ -90,6 +152,18 @@ func (h *MD5PasswordHasher) Verify(password string, encoded string) (bool, error
 	return hmac.Equal([]byte(newencoded), []byte(encoded)), nil
 }
 
+// MustUpdate returns true if the salt of the encoded digest
+// has less entropy than SaltEntropy.
+func (h *MD5PasswordHasher) MustUpdate(encoded string) bool {
+	d, err := h.Decode(encoded)
+
+	if err != nil {
+		return false
+	}
+
+	return !entropy.Enough(d.Salt, h.SaltEntropy)
+}
+
 // NewUnsaltedMD5PasswordHasher is an incredibly insecure algorithm
 // that should never be used. It stores unsalted MD5 hashes without
 // the algorithm prefix, also hashes with an empty salt.
@@ -105,6 +179,7 @@ func NewUnsaltedMD5PasswordHasher() *UnsaltedMD5PasswordHasher {
 // NewMD5PasswordHasher secures password hashing using Salted MD5 algorithm (not recommended).
 func NewMD5PasswordHasher() *MD5PasswordHasher {
 	return &MD5PasswordHasher{
-		Algorithm: "md5",
+		Algorithm:   "md5",
+		SaltEntropy: entropy.Default,
 	}
 }
@@ -0,0 +1,22 @@
+//go:build go1.18
+// +build go1.18
+
+package md5
+
+import "testing"
+
+func FuzzMD5Verify(f *testing.F) {
+	f.Add("md5$seasalt$f5531bef9f3687d0ccf0f617f0e25573")
+	f.Add("21232f297a57a5a743894a0e4a801fc3")
+	f.Add("md5$$21232f297a57a5a743894a0e4a801fc3")
+
+	f.Fuzz(func(t *testing.T, encoded string) {
+		h := NewMD5PasswordHasher()
+		h.Decode(encoded)
+		h.Verify("admin", encoded)
+
+		u := NewUnsaltedMD5PasswordHasher()
+		u.Decode(encoded)
+		u.Verify("admin", encoded)
+	})
+}
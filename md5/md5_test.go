@@ -67,3 +67,41 @@ func TestMD5PasswordVerify(t *testing.T) {
 		t.Fatal("Password should be valid.")
 	}
 }
+
+func TestUnsaltedMD5PasswordDecode(t *testing.T) {
+	d, err := NewUnsaltedMD5PasswordHasher().Decode("md5$$d24c80177269fb85874b1361e6b71fb4")
+
+	if err != nil {
+		t.Fatalf("Decode error: %s", err)
+	}
+
+	if d.Algorithm != "unsalted_md5" || d.Salt != "" || d.Hash != "d24c80177269fb85874b1361e6b71fb4" {
+		t.Fatalf("Decoded hash %+v does not match.", d)
+	}
+}
+
+func TestMD5PasswordDecode(t *testing.T) {
+	d, err := NewMD5PasswordHasher().Decode("md5$NMxMaHPlUEr7$5b7913a35d0cfbbd3e5ef243c84eadd1")
+
+	if err != nil {
+		t.Fatalf("Decode error: %s", err)
+	}
+
+	if d.Algorithm != "md5" || d.Salt != "NMxMaHPlUEr7" || d.Hash != "5b7913a35d0cfbbd3e5ef243c84eadd1" {
+		t.Fatalf("Decoded hash %+v does not match.", d)
+	}
+}
+
+func TestMD5PasswordMustUpdate(t *testing.T) {
+	h := NewMD5PasswordHasher()
+
+	if h.MustUpdate("md5$NMxMaHPlUEr7$5b7913a35d0cfbbd3e5ef243c84eadd1") {
+		t.Fatal("Hash with enough salt entropy should not be updated.")
+	}
+
+	h.SaltEntropy = 128
+
+	if !h.MustUpdate("md5$NMxMaHPlUEr7$5b7913a35d0cfbbd3e5ef243c84eadd1") {
+		t.Fatal("Hash with less salt entropy should be updated.")
+	}
+}
@@ -0,0 +1,186 @@
+package unchained
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// Option configures the behavior of CheckPassword, MakePassword and related functions.
+type Option func(*options)
+
+type options struct {
+	hardenRuntime bool
+	dummyCheck    bool
+	limits        *Limits
+	// Zero means no limit.
+	maxPasswordLength int
+	// Nil means the salt of each hasher.
+	saltGenerator SaltGenerator
+	// Zero means the salt entropy of each hasher.
+	saltEntropy int
+	// Zero means the salt length of each hasher.
+	saltLength int
+	// Parameters of MakePassword, zero means the hasher's value.
+	iterations    int
+	cost          int
+	argon2Time    uint32
+	argon2Memory  uint32
+	argon2Threads uint8
+	limiter       *Limiter
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{maxPasswordLength: DefaultMaxPasswordLength}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o
+}
+
+// run runs f with ctx, or within the limiter of o if any.
+//
+// Within a limiter, the hashers that stop when ctx is done give their
+// place back when they return. The other ones keep running in the
+// background, and the limiter holds their resources until they return,
+// even if run returns ctx.Err() first.
+func (o *options) run(ctx context.Context, memory int, f func(ctx context.Context) error) error {
+	if o.limiter == nil {
+		return f(ctx)
+	}
+
+	return o.limiter.Do(ctx, memory, func() error {
+		var background sync.WaitGroup
+		err := f(context.WithValue(ctx, backgroundKey{}, &background))
+		background.Wait()
+		return err
+	})
+}
+
+func (o *options) passwordTooLong(password string) bool {
+	return o.maxPasswordLength > 0 && len(password) > o.maxPasswordLength
+}
+
+// WithDummyCheck encodes a random password once with the preferred hasher
+// if the encoded password is unusable, like Django does, so the response
+// time does not reveal users without a usable password, even if the
+// password is empty.
+func WithDummyCheck() Option {
+	return func(o *options) {
+		o.dummyCheck = true
+	}
+}
+
+// WithHardenRuntime pads the verification of encoded passwords that use
+// weaker settings than the preferred hasher, like Django's harden_runtime,
+// so the response time does not reveal which users have outdated hashes.
+//
+// It has no effect on encoded passwords that use another hasher.
+func WithHardenRuntime() Option {
+	return func(o *options) {
+		o.hardenRuntime = true
+	}
+}
+
+// WithLimits rejects encoded passwords whose parameters exceed l
+// with an error of category ErrLimitExceeded, before verifying them.
+func WithLimits(l Limits) Option {
+	return func(o *options) {
+		o.limits = &l
+	}
+}
+
+// WithMaxPasswordLength rejects passwords longer than n bytes with
+// ErrPasswordTooLong, instead of DefaultMaxPasswordLength.
+// If n is zero or negative, the length is not limited.
+func WithMaxPasswordLength(n int) Option {
+	return func(o *options) {
+		o.maxPasswordLength = n
+	}
+}
+
+// WithSaltGenerator creates salts and unusable passwords with g,
+// instead of crypto/rand, so an entropy failure is returned as an error
+// and tests can use a deterministic source.
+//
+// Salts still have the length required by each built-in hasher.
+// Other hashers that implement Salter create their own salts.
+func WithSaltGenerator(g SaltGenerator) Option {
+	return func(o *options) {
+		o.saltGenerator = g
+	}
+}
+
+// WithSaltEntropy creates salts with at least bits of entropy,
+// instead of the SaltEntropy of the hasher.
+//
+// It has no effect on hashers with a fixed salt format, such as BCrypt and Crypt.
+func WithSaltEntropy(bits int) Option {
+	return func(o *options) {
+		o.saltEntropy = bits
+	}
+}
+
+// WithSaltLength creates salts with n characters,
+// instead of the length given by the salt entropy.
+//
+// It has no effect on hashers with a fixed salt format, such as BCrypt and Crypt.
+func WithSaltLength(n int) Option {
+	return func(o *options) {
+		o.saltLength = n
+	}
+}
+
+// WithRandom creates salts and unusable passwords with random bytes
+// read from r, it is a shortcut for WithSaltGenerator.
+func WithRandom(r io.Reader) Option {
+	return WithSaltGenerator(NewRandomStringGenerator(r, ""))
+}
+
+// WithIterations makes MakePassword encode PBKDF2 passwords
+// with n iterations, instead of the iterations of the hasher.
+//
+// It has no effect on other hashers.
+func WithIterations(n int) Option {
+	return func(o *options) {
+		o.iterations = n
+	}
+}
+
+// WithCost makes MakePassword encode BCrypt passwords
+// with the work factor cost, instead of the cost of the hasher.
+//
+// It has no effect on other hashers.
+func WithCost(cost int) Option {
+	return func(o *options) {
+		o.cost = cost
+	}
+}
+
+// WithArgon2Params makes MakePassword encode Argon2 passwords with the
+// time cost, memory cost in KiB and parallelism, instead of the parameters
+// of the hasher. Zero values keep the parameters of the hasher.
+//
+// It has no effect on other hashers.
+func WithArgon2Params(time, memory uint32, threads uint8) Option {
+	return func(o *options) {
+		o.argon2Time = time
+		o.argon2Memory = memory
+		o.argon2Threads = threads
+	}
+}
+
+// WithLimiter runs the encoding and verification of passwords within l,
+// which bounds the operations running at the same time and their memory.
+// ErrOverloaded is returned when the queue of l is full.
+//
+// If the context is done, a hasher that implements ContextHasher, such as
+// PBKDF2, stops and gives its place in l back. An operation that cannot be
+// interrupted, such as Argon2, keeps its place in l until the hasher returns.
+func WithLimiter(l *Limiter) Option {
+	return func(o *options) {
+		o.limiter = l
+	}
+}
@@ -0,0 +1,58 @@
+package pbkdf2
+
+import (
+	"context"
+	"crypto/hmac"
+	"hash"
+)
+
+// How many iterations run between checks of the context.
+const checkInterval = 1024
+
+// key derives a key from the password, salt and iteration count
+// as specified in RFC 2898, like "golang.org/x/crypto/pbkdf2".Key.
+//
+// It returns ctx.Err() as soon as ctx is done.
+func key(ctx context.Context, password, salt []byte, iterations, keyLen int, h func() hash.Hash) ([]byte, error) {
+	prf := hmac.New(h, password)
+	hashLen := prf.Size()
+	numBlocks := (keyLen + hashLen - 1) / hashLen
+
+	var buf [4]byte
+	dk := make([]byte, 0, numBlocks*hashLen)
+	u := make([]byte, hashLen)
+
+	for block := 1; block <= numBlocks; block++ {
+		// U_1 = PRF(password, salt || uint(i))
+		prf.Reset()
+		prf.Write(salt)
+		buf[0] = byte(block >> 24)
+		buf[1] = byte(block >> 16)
+		buf[2] = byte(block >> 8)
+		buf[3] = byte(block)
+		prf.Write(buf[:4])
+		dk = prf.Sum(dk)
+		t := dk[len(dk)-hashLen:]
+		copy(u, t)
+
+		// U_n = PRF(password, U_(n - 1))
+		for n := 2; n <= iterations; n++ {
+			if n%checkInterval == 0 {
+				if err := ctx.Err(); err != nil {
+					return nil, err
+				}
+			}
+
+			prf.Reset()
+			prf.Write(u)
+			u = u[:0]
+			u = prf.Sum(u)
+
+			for x := range u {
+				t[x] ^= u[x]
+			}
+		}
+	}
+
+	return dk[:keyLen], nil
+}
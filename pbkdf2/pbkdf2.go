@@ -1,27 +1,50 @@
 package pbkdf2
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/sha1"
 	"crypto/sha256"
 	"encoding/base64"
-	"errors"
 	"fmt"
 	"hash"
 	"strconv"
 	"strings"
 
-	"golang.org/x/crypto/pbkdf2"
+	"github.com/alexandrevicenzi/unchained/internal/entropy"
+	"github.com/alexandrevicenzi/unchained/internal/hasherr"
 )
 
 // Errors returned by PBKDF2Hasher.
+//
+// Errors about a component of an encoded password are wrapped
+// in an unchained.HashError, match them with errors.Is.
 var (
-	ErrHashComponentUnreadable = errors.New("unchained/pbkdf2: unreadable component in hashed password")
-	ErrHashComponentMismatch   = errors.New("unchained/pbkdf2: hashed password components mismatch")
-	ErrAlgorithmMismatch       = errors.New("unchained/pbkdf2: algorithm mismatch")
-	ErrSaltContainsDollarSing  = errors.New("unchained/pbkdf2: salt contains dollar sign ($)")
+	ErrHashComponentUnreadable = hasherr.New(hasherr.ErrMalformedHash, "unchained/pbkdf2: unreadable component in hashed password")
+	ErrHashComponentMismatch   = hasherr.New(hasherr.ErrMalformedHash, "unchained/pbkdf2: hashed password components mismatch")
+	ErrAlgorithmMismatch       = hasherr.New(hasherr.ErrUnsupportedAlgorithm, "unchained/pbkdf2: algorithm mismatch")
+	ErrSaltContainsDollarSing  = hasherr.New(hasherr.ErrInvalidSalt, "unchained/pbkdf2: salt contains dollar sign ($)")
+	ErrLimitExceeded           = hasherr.New(hasherr.ErrLimitExceeded, "unchained/pbkdf2: iterations exceed limit")
+	ErrInvalidIterations       = hasherr.New(hasherr.ErrInvalidParameters, "unchained/pbkdf2: iterations must be positive")
+	ErrPasswordTooLong         = hasherr.New(hasherr.ErrPasswordTooLong, "unchained/pbkdf2: password too long")
 )
 
+// DefaultMaxIterations is the limit used by Verify when MaxIterations is zero,
+// ten times the default of Django 5.2.
+const DefaultMaxIterations = 10000000
+
+// DecodedHash holds the components of a PBKDF2 encoded password.
+type DecodedHash struct {
+	// Algorithm identifier.
+	Algorithm string
+	// Number of rounds used to encode the password.
+	Iterations int
+	// Salt used to encode the password.
+	Salt string
+	// Base64 encoded hash.
+	Hash string
+}
+
 // PBKDF2Hasher implements PBKDF2 password hasher.
 type PBKDF2Hasher struct {
 	// Algorithm identifier.
@@ -32,44 +55,109 @@ type PBKDF2Hasher struct {
 	Size int
 	// Defines the hash function used to encode the password.
 	Digest func() hash.Hash
+	// Defines the maximum number of rounds accepted by Verify,
+	// zero means DefaultMaxIterations.
+	MaxIterations int
+	// Defines the maximum length of passwords in bytes, zero means no limit.
+	MaxPasswordLength int
+	// Defines the minimum entropy of salts in bits, zero means unchained.DefaultSaltEntropy.
+	// Encoded digests whose salt has less entropy must be updated.
+	SaltEntropy int
 }
 
 // Encode turns a plain-text password into a hash.
 func (h *PBKDF2Hasher) Encode(password string, salt string, iterations int) (string, error) {
+	return h.EncodeContext(context.Background(), password, salt, iterations)
+}
+
+// EncodeContext turns a plain-text password into a hash.
+//
+// It returns ctx.Err() as soon as ctx is done.
+func (h *PBKDF2Hasher) EncodeContext(ctx context.Context, password string, salt string, iterations int) (string, error) {
+	if h.MaxPasswordLength > 0 && len(password) > h.MaxPasswordLength {
+		return "", ErrPasswordTooLong
+	}
+
 	if strings.Contains(salt, "$") {
-		return "", ErrSaltContainsDollarSing
+		return "", hasherr.Wrap(h.Algorithm, "salt", ErrSaltContainsDollarSing)
 	}
 
 	if iterations <= 0 {
 		iterations = h.Iterations
 	}
 
-	hash := pbkdf2.Key([]byte(password), []byte(salt), iterations, h.Size, h.Digest)
+	if iterations < 1 {
+		return "", hasherr.Wrap(h.Algorithm, "iterations", ErrInvalidIterations)
+	}
+
+	hash, err := key(ctx, []byte(password), []byte(salt), iterations, h.Size, h.Digest)
+
+	if err != nil {
+		return "", err
+	}
+
 	b64Hash := base64.StdEncoding.EncodeToString(hash)
 	return fmt.Sprintf("%s$%d$%s$%s", h.Algorithm, iterations, salt, b64Hash), nil
 }
 
-// Verify if a plain-text password matches the encoded digest.
-func (h *PBKDF2Hasher) Verify(password string, encoded string) (bool, error) {
+// Decode splits the encoded digest into its components.
+func (h *PBKDF2Hasher) Decode(encoded string) (*DecodedHash, error) {
 	s := strings.Split(encoded, "$")
 
 	if len(s) != 4 {
-		return false, ErrHashComponentMismatch
+		return nil, ErrHashComponentMismatch
 	}
 
-	algorithm, iterations, salt := s[0], s[1], s[2]
+	algorithm, iterations, salt, hash := s[0], s[1], s[2], s[3]
 
 	if algorithm != h.Algorithm {
-		return false, ErrAlgorithmMismatch
+		return nil, hasherr.Wrap(h.Algorithm, "algorithm", ErrAlgorithmMismatch)
 	}
 
 	i, err := strconv.Atoi(iterations)
 
 	if err != nil {
-		return false, ErrHashComponentUnreadable
+		return nil, hasherr.Wrap(h.Algorithm, "iterations", ErrHashComponentUnreadable)
+	}
+
+	return &DecodedHash{
+		Algorithm:  algorithm,
+		Iterations: i,
+		Salt:       salt,
+		Hash:       hash,
+	}, nil
+}
+
+// Verify if a plain-text password matches the encoded digest.
+func (h *PBKDF2Hasher) Verify(password string, encoded string) (bool, error) {
+	return h.VerifyContext(context.Background(), password, encoded)
+}
+
+// VerifyContext checks if a plain-text password matches the encoded digest.
+//
+// It returns ctx.Err() as soon as ctx is done.
+func (h *PBKDF2Hasher) VerifyContext(ctx context.Context, password string, encoded string) (bool, error) {
+	d, err := h.Decode(encoded)
+
+	if err != nil {
+		return false, err
+	}
+
+	max := h.MaxIterations
+
+	if max <= 0 {
+		max = DefaultMaxIterations
 	}
 
-	newencoded, err := h.Encode(password, salt, i)
+	if d.Iterations < 1 {
+		return false, hasherr.Wrap(h.Algorithm, "iterations", ErrInvalidIterations)
+	}
+
+	if d.Iterations > max {
+		return false, hasherr.Wrap(h.Algorithm, "iterations", ErrLimitExceeded)
+	}
+
+	newencoded, err := h.EncodeContext(ctx, password, d.Salt, d.Iterations)
 
 	if err != nil {
 		return false, err
@@ -78,6 +166,43 @@ func (h *PBKDF2Hasher) Verify(password string, encoded string) (bool, error) {
 	return hmac.Equal([]byte(newencoded), []byte(encoded)), nil
 }
 
+// MustUpdate returns true if the encoded digest uses
+// a different number of iterations than the hasher,
+// or a salt with less entropy than SaltEntropy.
+func (h *PBKDF2Hasher) MustUpdate(encoded string) bool {
+	d, err := h.Decode(encoded)
+
+	if err != nil {
+		return false
+	}
+
+	return d.Iterations != h.Iterations ||
+		!entropy.Enough(d.Salt, h.SaltEntropy)
+}
+
+// HardenRuntime runs the iterations missing from the encoded digest
+// to match the hasher, so verification takes the same time regardless
+// of the number of iterations used to encode the password.
+func (h *PBKDF2Hasher) HardenRuntime(password string, encoded string) error {
+	d, err := h.Decode(encoded)
+
+	if err != nil {
+		return err
+	}
+
+	if d.Iterations < 1 {
+		return hasherr.Wrap(h.Algorithm, "iterations", ErrInvalidIterations)
+	}
+
+	extra := h.Iterations - d.Iterations
+
+	if extra > 0 {
+		_, err = key(context.Background(), []byte(password), []byte(d.Salt), extra, h.Size, h.Digest)
+	}
+
+	return err
+}
+
 // NewPBKDF2SHA1Hasher secures password hashing using the PBKDF2 algorithm.
 //
 // Alternate PBKDF2 hasher which uses SHA1, the default PRF
@@ -87,10 +212,11 @@ func (h *PBKDF2Hasher) Verify(password string, encoded string) (bool, error) {
 // such as openssl's PKCS5_PBKDF2_HMAC_SHA1().
 func NewPBKDF2SHA1Hasher() *PBKDF2Hasher {
 	return &PBKDF2Hasher{
-		Algorithm:  "pbkdf2_sha1",
-		Iterations: 216000,
-		Size:       sha1.Size,
-		Digest:     sha1.New,
+		Algorithm:   "pbkdf2_sha1",
+		Iterations:  216000,
+		Size:        sha1.Size,
+		Digest:      sha1.New,
+		SaltEntropy: entropy.Default,
 	}
 }
 
@@ -100,9 +226,10 @@ func NewPBKDF2SHA1Hasher() *PBKDF2Hasher {
 // The result is a 64 byte binary string.
 func NewPBKDF2SHA256Hasher() *PBKDF2Hasher {
 	return &PBKDF2Hasher{
-		Algorithm:  "pbkdf2_sha256",
-		Iterations: 216000,
-		Size:       sha256.Size,
-		Digest:     sha256.New,
+		Algorithm:   "pbkdf2_sha256",
+		Iterations:  216000,
+		Size:        sha256.Size,
+		Digest:      sha256.New,
+		SaltEntropy: entropy.Default,
 	}
 }
@@ -0,0 +1,23 @@
+//go:build go1.18
+// +build go1.18
+
+package pbkdf2
+
+import "testing"
+
+func FuzzPBKDF2Verify(f *testing.F) {
+	f.Add("pbkdf2_sha256$1000$WZrFZhpl3wOU$yPimyWN658IuAu0XErvg1Nowfd55k60hu4o+eDUlBDM=")
+	f.Add("pbkdf2_sha256$0$salt$")
+	f.Add("pbkdf2_sha256$-1$salt$")
+
+	f.Fuzz(func(t *testing.T, encoded string) {
+		h := NewPBKDF2SHA256Hasher()
+		h.Iterations = 1000
+		h.MaxIterations = 1000
+
+		h.Decode(encoded)
+		h.Verify("admin", encoded)
+		h.MustUpdate(encoded)
+		h.HardenRuntime("admin", encoded)
+	})
+}
@@ -1,7 +1,10 @@
 package pbkdf2
 
 import (
+	"context"
+	"errors"
 	"testing"
+	"time"
 )
 
 func TestPBKDF2SHA1Encode1(t *testing.T) {
@@ -235,3 +238,132 @@ func TestPBKDF2SHA256VerifyInvalidPassword(t *testing.T) {
 		t.Fatal("Password should not be valid.")
 	}
 }
+
+func TestPBKDF2SHA256Decode(t *testing.T) {
+	d, err := NewPBKDF2SHA256Hasher().Decode("pbkdf2_sha256$120000$WZrFZhpl3wOU$yPimyWN658IuAu0XErvg1Nowfd55k60hu4o+eDUlBDM=")
+
+	if err != nil {
+		t.Fatalf("Decode error: %s", err)
+	}
+
+	if d.Algorithm != "pbkdf2_sha256" || d.Iterations != 120000 || d.Salt != "WZrFZhpl3wOU" || d.Hash != "yPimyWN658IuAu0XErvg1Nowfd55k60hu4o+eDUlBDM=" {
+		t.Fatalf("Decoded hash %+v does not match.", d)
+	}
+}
+
+func TestPBKDF2SHA256MustUpdate(t *testing.T) {
+	h := NewPBKDF2SHA256Hasher()
+
+	if !h.MustUpdate("pbkdf2_sha256$120000$WZrFZhpl3wOU$yPimyWN658IuAu0XErvg1Nowfd55k60hu4o+eDUlBDM=") {
+		t.Fatal("Hash with less iterations should be updated.")
+	}
+
+	h.Iterations = 120000
+
+	if h.MustUpdate("pbkdf2_sha256$120000$WZrFZhpl3wOU$yPimyWN658IuAu0XErvg1Nowfd55k60hu4o+eDUlBDM=") {
+		t.Fatal("Hash with same iterations should not be updated.")
+	}
+
+	h.SaltEntropy = 128
+
+	if !h.MustUpdate("pbkdf2_sha256$120000$WZrFZhpl3wOU$yPimyWN658IuAu0XErvg1Nowfd55k60hu4o+eDUlBDM=") {
+		t.Fatal("Hash with less salt entropy should be updated.")
+	}
+}
+
+func TestPBKDF2SHA256HardenRuntime(t *testing.T) {
+	err := NewPBKDF2SHA256Hasher().HardenRuntime("admin", "pbkdf2_sha256$120000$WZrFZhpl3wOU$yPimyWN658IuAu0XErvg1Nowfd55k60hu4o+eDUlBDM=")
+
+	if err != nil {
+		t.Fatalf("HardenRuntime error: %s", err)
+	}
+}
+
+func TestPBKDF2SHA256EncodeContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := NewPBKDF2SHA256Hasher().EncodeContext(ctx, "admin", "WZrFZhpl3wOU", 0)
+
+	if err != context.Canceled {
+		t.Fatalf("Error %v is not %s.", err, context.Canceled)
+	}
+}
+
+func TestPBKDF2SHA256VerifyContextDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := NewPBKDF2SHA256Hasher().VerifyContext(ctx, "admin", "pbkdf2_sha256$9000000$WZrFZhpl3wOU$yPimyWN658IuAu0XErvg1Nowfd55k60hu4o+eDUlBDM=")
+
+	if err != context.DeadlineExceeded {
+		t.Fatalf("Error %v is not %s.", err, context.DeadlineExceeded)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Verification took %s after the deadline.", elapsed)
+	}
+}
+
+func TestPBKDF2SHA256VerifyContext(t *testing.T) {
+	valid, err := NewPBKDF2SHA256Hasher().VerifyContext(context.Background(), "admin", "pbkdf2_sha256$120000$WZrFZhpl3wOU$yPimyWN658IuAu0XErvg1Nowfd55k60hu4o+eDUlBDM=")
+
+	if err != nil {
+		t.Fatalf("Verify error: %s", err)
+	}
+
+	if !valid {
+		t.Fatal("Password should be valid.")
+	}
+}
+
+func TestPBKDF2SHA256VerifyLimit(t *testing.T) {
+	_, err := NewPBKDF2SHA256Hasher().Verify("admin", "pbkdf2_sha256$1000000000$WZrFZhpl3wOU$yPimyWN658IuAu0XErvg1Nowfd55k60hu4o+eDUlBDM=")
+
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("Error %v is not %s.", err, ErrLimitExceeded)
+	}
+
+	h := NewPBKDF2SHA256Hasher()
+	h.MaxIterations = 100000
+
+	_, err = h.Verify("admin", "pbkdf2_sha256$120000$WZrFZhpl3wOU$yPimyWN658IuAu0XErvg1Nowfd55k60hu4o+eDUlBDM=")
+
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("Error %v is not %s.", err, ErrLimitExceeded)
+	}
+}
+
+func TestPBKDF2SHA256VerifyInvalidIterations(t *testing.T) {
+	for _, encoded := range []string{
+		"pbkdf2_sha256$0$WZrFZhpl3wOU$yPimyWN658IuAu0XErvg1Nowfd55k60hu4o+eDUlBDM=",
+		"pbkdf2_sha256$-120000$WZrFZhpl3wOU$yPimyWN658IuAu0XErvg1Nowfd55k60hu4o+eDUlBDM=",
+	} {
+		_, err := NewPBKDF2SHA256Hasher().Verify("admin", encoded)
+
+		if !errors.Is(err, ErrInvalidIterations) {
+			t.Fatalf("Error %v is not %s for %s.", err, ErrInvalidIterations, encoded)
+		}
+	}
+}
+
+func TestPBKDF2SHA256MaxPasswordLength(t *testing.T) {
+	h := NewPBKDF2SHA256Hasher()
+	h.MaxPasswordLength = 4
+
+	if _, err := h.Encode("admin", "WZrFZhpl3wOU", 0); err != ErrPasswordTooLong {
+		t.Fatalf("Error %v is not %s.", err, ErrPasswordTooLong)
+	}
+
+	if _, err := h.Verify("admin", "pbkdf2_sha256$120000$WZrFZhpl3wOU$yPimyWN658IuAu0XErvg1Nowfd55k60hu4o+eDUlBDM="); err != ErrPasswordTooLong {
+		t.Fatalf("Error %v is not %s.", err, ErrPasswordTooLong)
+	}
+}
+
+func TestPBKDF2SHA256DecodeComponentMismatch(t *testing.T) {
+	// Errors without a component are returned as is.
+	if _, err := NewPBKDF2SHA256Hasher().Decode("pbkdf2_sha256$120000$WZrFZhpl3wOU"); err != ErrHashComponentMismatch {
+		t.Fatalf("Error %v is not %s.", err, ErrHashComponentMismatch)
+	}
+}
@@ -0,0 +1,216 @@
+package unchained
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/alexandrevicenzi/unchained/internal/hasherr"
+)
+
+// PepperPrefix is prepended to the algorithm of peppered hashers.
+const PepperPrefix = "pepper_"
+
+// Errors returned by peppered hashers.
+var (
+	// ErrPepperKeyNotFound is returned if the pepper key of an encoded password
+	// or the current key of a Keyring is not in the keyring.
+	ErrPepperKeyNotFound = hasherr.New(ErrUnsupportedVersion, "unchained: pepper key not found")
+	// ErrInvalidPepperKey is returned if a Keyring has an empty key,
+	// or a key ID that is empty or contains a dollar sign ($).
+	ErrInvalidPepperKey = hasherr.New(ErrInvalidParameters, "unchained: invalid pepper key")
+	// ErrPepperComponentMismatch is returned if a peppered password
+	// does not have the algorithm, key ID and inner encoded password.
+	ErrPepperComponentMismatch = hasherr.New(ErrMalformedHash, "unchained: peppered password components mismatch")
+)
+
+// Keyring holds the pepper keys, identified by a key ID
+// that is stored in the encoded passwords.
+//
+// To rotate the pepper, add a new key and make it current.
+// Keep the retired keys, so existing passwords can be verified
+// and reported as must update.
+type Keyring struct {
+	// ID of the key used to encode new passwords.
+	Current string
+	// Keys by ID.
+	Keys map[string][]byte
+}
+
+type pepperHasher struct {
+	h       PasswordHasher
+	current string
+	keys    map[string][]byte
+}
+
+// Pepper returns a PasswordHasher that applies HMAC-SHA256 with a key
+// of the keyring to the password before encoding it with h.
+//
+// Encoded passwords have the format pepper_<algorithm>$<key ID>$<encoded>,
+// where <encoded> is the password encoded by h. Passwords encoded by h
+// without pepper keep their format and are not accepted by the hasher.
+//
+// The keyring is copied, use a new hasher to rotate keys.
+func Pepper(h PasswordHasher, k Keyring) (PasswordHasher, error) {
+	keys := make(map[string][]byte, len(k.Keys))
+
+	for id, key := range k.Keys {
+		if id == "" || strings.Contains(id, "$") || len(key) == 0 {
+			return nil, ErrInvalidPepperKey
+		}
+
+		keys[id] = append([]byte(nil), key...)
+	}
+
+	if _, ok := keys[k.Current]; !ok {
+		return nil, ErrPepperKeyNotFound
+	}
+
+	return &pepperHasher{h: h, current: k.Current, keys: keys}, nil
+}
+
+func (p *pepperHasher) Algorithm() string {
+	return PepperPrefix + p.h.Algorithm()
+}
+
+// pepper returns the hex encoded HMAC-SHA256 of the password,
+// which fits the 72 bytes used by bcrypt.
+func (p *pepperHasher) pepper(id, password string) (string, error) {
+	key, ok := p.keys[id]
+
+	if !ok {
+		return "", hasherr.Wrap(p.Algorithm(), "pepper_key", ErrPepperKeyNotFound)
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(password))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// split returns the key ID and the inner encoded password.
+func (p *pepperHasher) split(encoded string) (id, inner string, err error) {
+	s := strings.SplitN(encoded, "$", 3)
+
+	if len(s) != 3 || s[0] != p.Algorithm() || s[1] == "" {
+		return "", "", ErrPepperComponentMismatch
+	}
+
+	return s[1], s[2], nil
+}
+
+func (p *pepperHasher) Encode(password, salt string) (string, error) {
+	return p.EncodeContext(context.Background(), password, salt)
+}
+
+func (p *pepperHasher) Verify(password, encoded string) (bool, error) {
+	return p.VerifyContext(context.Background(), password, encoded)
+}
+
+func (p *pepperHasher) EncodeContext(ctx context.Context, password, salt string) (string, error) {
+	peppered, err := p.pepper(p.current, password)
+
+	if err != nil {
+		return "", err
+	}
+
+	encoded, err := encode(ctx, p.h, peppered, salt)
+
+	if err != nil {
+		return "", err
+	}
+
+	return p.Algorithm() + "$" + p.current + "$" + encoded, nil
+}
+
+func (p *pepperHasher) VerifyContext(ctx context.Context, password, encoded string) (bool, error) {
+	id, inner, err := p.split(encoded)
+
+	if err != nil {
+		return false, err
+	}
+
+	peppered, err := p.pepper(id, password)
+
+	if err != nil {
+		return false, err
+	}
+
+	return verify(ctx, p.h, peppered, inner)
+}
+
+func (p *pepperHasher) Decode(encoded string) (*DecodedHash, error) {
+	id, inner, err := p.split(encoded)
+
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := p.h.Decode(inner)
+
+	if err != nil {
+		return nil, err
+	}
+
+	d.Algorithm = p.Algorithm()
+	d.PepperKey = id
+
+	return d, nil
+}
+
+// MustUpdate returns true if the encoded password uses a retired
+// pepper key, or if the inner hasher must update it.
+func (p *pepperHasher) MustUpdate(encoded string) bool {
+	id, inner, err := p.split(encoded)
+
+	if err != nil {
+		return false
+	}
+
+	return id != p.current || p.h.MustUpdate(inner)
+}
+
+func (p *pepperHasher) HardenRuntime(password, encoded string) error {
+	r, ok := p.h.(RuntimeHardener)
+
+	if !ok {
+		return nil
+	}
+
+	id, inner, err := p.split(encoded)
+
+	if err != nil {
+		return err
+	}
+
+	peppered, err := p.pepper(id, password)
+
+	if err != nil {
+		return err
+	}
+
+	return r.HardenRuntime(peppered, inner)
+}
+
+func (p *pepperHasher) Salt() (string, error) {
+	return newSalt(p.h, &options{})
+}
+
+func (p *pepperHasher) saltFrom(g SaltGenerator, length int) (string, error) {
+	return newSalt(p.h, &options{saltGenerator: g, saltLength: length})
+}
+
+func (p *pepperHasher) withParams(o *options) PasswordHasher {
+	ph, ok := p.h.(paramsHasher)
+
+	if !ok {
+		return p
+	}
+
+	return &pepperHasher{h: ph.withParams(o), current: p.current, keys: p.keys}
+}
+
+func (p *pepperHasher) memoryCost() int {
+	return encodeMemory(p.h)
+}
@@ -0,0 +1,205 @@
+package unchained
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/alexandrevicenzi/unchained/argon2"
+	"github.com/alexandrevicenzi/unchained/bcrypt"
+	"github.com/alexandrevicenzi/unchained/pbkdf2"
+)
+
+func newPepperHasher(t *testing.T, h PasswordHasher, current string) PasswordHasher {
+	p, err := Pepper(h, Keyring{
+		Current: current,
+		Keys: map[string][]byte{
+			"k1": []byte("first secret"),
+			"k2": []byte("second secret"),
+		},
+	})
+
+	if err != nil {
+		t.Fatalf("Pepper error: %s", err)
+	}
+
+	return p
+}
+
+func newFastPBKDF2() PasswordHasher {
+	h := pbkdf2.NewPBKDF2SHA256Hasher()
+	h.Iterations = 1000
+	return PBKDF2(h)
+}
+
+func TestPepperEncodeVerify(t *testing.T) {
+	a := argon2.NewArgon2Hasher()
+	a.Memory = 64
+	a.Threads = 1
+	b := bcrypt.NewBCryptHasher()
+	b.Cost = 4
+
+	for _, h := range []PasswordHasher{newFastPBKDF2(), Argon2(a), BCrypt(b)} {
+		p := newPepperHasher(t, h, "k1")
+		encoded, err := p.Encode("admin", "")
+
+		if err != nil {
+			t.Fatalf("Encode error: %s", err)
+		}
+
+		prefix := "pepper_" + h.Algorithm() + "$k1$" + h.Algorithm() + "$"
+
+		if !strings.HasPrefix(encoded, prefix) {
+			t.Fatalf("Encoded password %s does not start with %s.", encoded, prefix)
+		}
+
+		if valid, err := p.Verify("admin", encoded); !valid || err != nil {
+			t.Fatalf("Password should be valid, error: %v", err)
+		}
+
+		if valid, _ := p.Verify("wrong", encoded); valid {
+			t.Fatal("Password should not be valid.")
+		}
+
+		// The inner hash does not match the password without pepper.
+		if valid, _ := h.Verify("admin", encoded[len("pepper_"+h.Algorithm()+"$k1$"):]); valid {
+			t.Fatal("Password should not be valid without pepper.")
+		}
+
+		d, err := p.Decode(encoded)
+
+		if err != nil {
+			t.Fatalf("Decode error: %s", err)
+		}
+
+		if d.Algorithm != p.Algorithm() || d.PepperKey != "k1" {
+			t.Fatalf("Decoded hash %+v does not match.", d)
+		}
+	}
+}
+
+func TestPepperKeys(t *testing.T) {
+	h := newFastPBKDF2()
+	e1, _ := newPepperHasher(t, h, "k1").Encode("admin", "seasalt")
+	e2, _ := newPepperHasher(t, h, "k2").Encode("admin", "seasalt")
+
+	if strings.TrimPrefix(e1, "pepper_pbkdf2_sha256$k1$") == strings.TrimPrefix(e2, "pepper_pbkdf2_sha256$k2$") {
+		t.Fatal("Passwords encoded with different keys should not match.")
+	}
+}
+
+func TestPepperRotation(t *testing.T) {
+	encoded, err := newPepperHasher(t, newFastPBKDF2(), "k1").Encode("admin", "seasalt")
+
+	if err != nil {
+		t.Fatalf("Encode error: %s", err)
+	}
+
+	c, err := NewContext(newPepperHasher(t, newFastPBKDF2(), "k2"))
+
+	if err != nil {
+		t.Fatalf("NewContext error: %s", err)
+	}
+
+	if !c.NeedsRehash(encoded) {
+		t.Fatal("Password with a retired key should need rehash.")
+	}
+
+	var updated string
+
+	valid, err := c.CheckPasswordWithSetter("admin", encoded, func(encoded string) error {
+		updated = encoded
+		return nil
+	}, "default")
+
+	if !valid || err != nil {
+		t.Fatalf("Password should be valid, error: %v", err)
+	}
+
+	if !strings.HasPrefix(updated, "pepper_pbkdf2_sha256$k2$") {
+		t.Fatalf("Password should be updated to the current key: %s", updated)
+	}
+
+	if c.NeedsRehash(updated) {
+		t.Fatalf("Updated password should not need rehash: %s", updated)
+	}
+}
+
+func TestPepperUnknownKey(t *testing.T) {
+	p, err := Pepper(newFastPBKDF2(), Keyring{Current: "k2", Keys: map[string][]byte{"k2": []byte("second secret")}})
+
+	if err != nil {
+		t.Fatalf("Pepper error: %s", err)
+	}
+
+	encoded, _ := newPepperHasher(t, newFastPBKDF2(), "k1").Encode("admin", "seasalt")
+	_, err = p.Verify("admin", encoded)
+
+	if !errors.Is(err, ErrPepperKeyNotFound) {
+		t.Fatalf("Error %v is not %s.", err, ErrPepperKeyNotFound)
+	}
+
+	var herr *HashError
+
+	if !errors.As(err, &herr) || herr.Component != "pepper_key" {
+		t.Fatalf("Error %v does not report the pepper key.", err)
+	}
+
+	if _, err := p.Verify("admin", "pepper_pbkdf2_sha256$$pbkdf2_sha256$1000$seasalt$hash"); !errors.Is(err, ErrMalformedHash) {
+		t.Fatalf("Error %v is not %s.", err, ErrMalformedHash)
+	}
+}
+
+func TestPepperInvalidKeyring(t *testing.T) {
+	for _, k := range []Keyring{
+		{Current: "k1", Keys: map[string][]byte{"k1": nil}},
+		{Current: "k$1", Keys: map[string][]byte{"k$1": []byte("secret")}},
+		{Current: "", Keys: map[string][]byte{"": []byte("secret")}},
+	} {
+		if _, err := Pepper(newFastPBKDF2(), k); err != ErrInvalidPepperKey {
+			t.Fatalf("Error %v is not %s.", err, ErrInvalidPepperKey)
+		}
+	}
+
+	if _, err := Pepper(newFastPBKDF2(), Keyring{Current: "k2", Keys: map[string][]byte{"k1": []byte("secret")}}); err != ErrPepperKeyNotFound {
+		t.Fatalf("Error %v is not %s.", err, ErrPepperKeyNotFound)
+	}
+}
+
+func TestPepperPlainPasswords(t *testing.T) {
+	c, err := NewContext(newPepperHasher(t, newFastPBKDF2(), "k1"), newFastPBKDF2())
+
+	if err != nil {
+		t.Fatalf("NewContext error: %s", err)
+	}
+
+	encoded := "pbkdf2_sha256$120000$WZrFZhpl3wOU$yPimyWN658IuAu0XErvg1Nowfd55k60hu4o+eDUlBDM="
+
+	if valid, err := c.CheckPassword("admin", encoded); !valid || err != nil {
+		t.Fatalf("Password should be valid, error: %v", err)
+	}
+
+	if !c.NeedsRehash(encoded) {
+		t.Fatal("Password without pepper should need rehash.")
+	}
+
+	peppered, err := c.MakePassword("admin", "", "default", WithIterations(2000))
+
+	if err != nil {
+		t.Fatalf("MakePassword error: %s", err)
+	}
+
+	if !strings.HasPrefix(peppered, "pepper_pbkdf2_sha256$k1$pbkdf2_sha256$2000$") {
+		t.Fatalf("Encoded password %s does not use the pepper hasher.", peppered)
+	}
+
+	d, err := c.Decode(peppered)
+
+	if err != nil {
+		t.Fatalf("Decode error: %s", err)
+	}
+
+	if len(d.Salt) != DefaultSaltSize || d.Params["iterations"] != 2000 {
+		t.Fatalf("Decoded hash %+v does not match.", d)
+	}
+}
@@ -0,0 +1,269 @@
+package unchained
+
+import (
+	"github.com/alexandrevicenzi/unchained/argon2"
+	"github.com/alexandrevicenzi/unchained/bcrypt"
+	"github.com/alexandrevicenzi/unchained/crypt"
+	"github.com/alexandrevicenzi/unchained/md5"
+	"github.com/alexandrevicenzi/unchained/pbkdf2"
+	"github.com/alexandrevicenzi/unchained/scrypt"
+	"github.com/alexandrevicenzi/unchained/sha1"
+)
+
+// Profile holds the default hasher parameters of a Django release.
+//
+// Use it to encode and verify passwords like the Django version
+// that shares the database.
+type Profile struct {
+	// Django release, e.g. "4.2".
+	Version string
+	// Django's default PASSWORD_HASHERS, in order of preference.
+	Hashers []string
+	// Number of PBKDF2 iterations.
+	Iterations int
+	// Argon2 variant, time cost, memory cost (KiB), parallelism
+	// and hash length in bytes.
+	Argon2Variant string
+	Argon2Time    uint32
+	Argon2Memory  uint32
+	Argon2Threads uint8
+	Argon2Length  uint32
+	// BCrypt work factor.
+	BCryptCost int
+	// Entropy of new salts in bits. Salts with less entropy must be updated.
+	SaltEntropy int
+}
+
+// djangoHashers returns Django's default PASSWORD_HASHERS,
+// with scrypt since Django 4.0.
+func djangoHashers(withScrypt bool) []string {
+	list := []string{
+		PBKDF2SHA256Hasher,
+		PBKDF2SHA1Hasher,
+		Argon2Hasher,
+		BCryptSHA256Hasher,
+	}
+
+	if withScrypt {
+		list = append(list, ScryptHasher)
+	}
+
+	return list
+}
+
+// Since Django 3.2, salts have 22 characters,
+// which is 128 bits of entropy.
+const django32SaltEntropy = 128
+
+// Profiles of Django releases.
+var (
+	Django30 = Profile{
+		Version:       "3.0",
+		Hashers:       djangoHashers(false),
+		Iterations:    180000,
+		Argon2Variant: argon2.Argon2i,
+		Argon2Time:    2,
+		Argon2Memory:  512,
+		Argon2Threads: 2,
+		Argon2Length:  16,
+		BCryptCost:    12,
+		SaltEntropy:   DefaultSaltEntropy,
+	}
+	Django31 = Profile{
+		Version:       "3.1",
+		Hashers:       djangoHashers(false),
+		Iterations:    216000,
+		Argon2Variant: argon2.Argon2i,
+		Argon2Time:    2,
+		Argon2Memory:  512,
+		Argon2Threads: 2,
+		Argon2Length:  16,
+		BCryptCost:    12,
+		SaltEntropy:   DefaultSaltEntropy,
+	}
+	Django32 = Profile{
+		Version:       "3.2",
+		Hashers:       djangoHashers(false),
+		Iterations:    260000,
+		Argon2Variant: argon2.Argon2id,
+		Argon2Time:    2,
+		Argon2Memory:  102400,
+		Argon2Threads: 8,
+		Argon2Length:  32,
+		BCryptCost:    12,
+		SaltEntropy:   django32SaltEntropy,
+	}
+	Django40 = Profile{
+		Version:       "4.0",
+		Hashers:       djangoHashers(true),
+		Iterations:    320000,
+		Argon2Variant: argon2.Argon2id,
+		Argon2Time:    2,
+		Argon2Memory:  102400,
+		Argon2Threads: 8,
+		Argon2Length:  32,
+		BCryptCost:    12,
+		SaltEntropy:   django32SaltEntropy,
+	}
+	Django41 = Profile{
+		Version:       "4.1",
+		Hashers:       djangoHashers(true),
+		Iterations:    390000,
+		Argon2Variant: argon2.Argon2id,
+		Argon2Time:    2,
+		Argon2Memory:  102400,
+		Argon2Threads: 8,
+		Argon2Length:  32,
+		BCryptCost:    12,
+		SaltEntropy:   django32SaltEntropy,
+	}
+	Django42 = Profile{
+		Version:       "4.2",
+		Hashers:       djangoHashers(true),
+		Iterations:    600000,
+		Argon2Variant: argon2.Argon2id,
+		Argon2Time:    2,
+		Argon2Memory:  102400,
+		Argon2Threads: 8,
+		Argon2Length:  32,
+		BCryptCost:    12,
+		SaltEntropy:   django32SaltEntropy,
+	}
+	Django50 = Profile{
+		Version:       "5.0",
+		Hashers:       djangoHashers(true),
+		Iterations:    720000,
+		Argon2Variant: argon2.Argon2id,
+		Argon2Time:    2,
+		Argon2Memory:  102400,
+		Argon2Threads: 8,
+		Argon2Length:  32,
+		BCryptCost:    12,
+		SaltEntropy:   django32SaltEntropy,
+	}
+	Django51 = Profile{
+		Version:       "5.1",
+		Hashers:       djangoHashers(true),
+		Iterations:    870000,
+		Argon2Variant: argon2.Argon2id,
+		Argon2Time:    2,
+		Argon2Memory:  102400,
+		Argon2Threads: 8,
+		Argon2Length:  32,
+		BCryptCost:    12,
+		SaltEntropy:   django32SaltEntropy,
+	}
+	Django52 = Profile{
+		Version:       "5.2",
+		Hashers:       djangoHashers(true),
+		Iterations:    1000000,
+		Argon2Variant: argon2.Argon2id,
+		Argon2Time:    2,
+		Argon2Memory:  102400,
+		Argon2Threads: 8,
+		Argon2Length:  32,
+		BCryptCost:    12,
+		SaltEntropy:   django32SaltEntropy,
+	}
+)
+
+// Hasher returns the hasher for the algorithm configured with the profile parameters.
+func (p Profile) Hasher(algorithm string) (PasswordHasher, error) {
+	switch algorithm {
+	case Argon2Hasher:
+		h := argon2.NewArgon2Hasher()
+		h.Variant = p.Argon2Variant
+		h.Time = p.Argon2Time
+		h.Memory = p.Argon2Memory
+		h.Threads = p.Argon2Threads
+		h.Length = p.Argon2Length
+		h.SaltEntropy = p.SaltEntropy
+		return Argon2(h), nil
+	case BCryptHasher:
+		h := bcrypt.NewBCryptHasher()
+		h.Cost = p.BCryptCost
+		return BCrypt(h), nil
+	case BCryptSHA256Hasher:
+		h := bcrypt.NewBCryptSHA256Hasher()
+		h.Cost = p.BCryptCost
+		return BCrypt(h), nil
+	case CryptHasher:
+		return Crypt(crypt.NewCryptPasswordHasher()), nil
+	case MD5Hasher:
+		h := md5.NewMD5PasswordHasher()
+		h.SaltEntropy = p.SaltEntropy
+		return MD5(h), nil
+	case PBKDF2SHA1Hasher:
+		h := pbkdf2.NewPBKDF2SHA1Hasher()
+		h.Iterations = p.Iterations
+		h.SaltEntropy = p.SaltEntropy
+		return PBKDF2(h), nil
+	case PBKDF2SHA256Hasher:
+		h := pbkdf2.NewPBKDF2SHA256Hasher()
+		h.Iterations = p.Iterations
+		h.SaltEntropy = p.SaltEntropy
+		return PBKDF2(h), nil
+	case ScryptHasher:
+		h := scrypt.NewScryptPasswordHasher()
+		h.SaltEntropy = p.SaltEntropy
+		return Scrypt(h), nil
+	case SHA1Hasher:
+		h := sha1.NewSHA1PasswordHasher()
+		h.SaltEntropy = p.SaltEntropy
+		return SHA1(h), nil
+	case UnsaltedMD5Hasher:
+		return UnsaltedMD5(md5.NewUnsaltedMD5PasswordHasher()), nil
+	case UnsaltedSHA1Hasher:
+		return SHA1(sha1.NewUnsaltedSHA1PasswordHasher()), nil
+	}
+
+	return nil, ErrInvalidHasher
+}
+
+// NewContext returns a Context with the default PASSWORD_HASHERS of the profile.
+func (p Profile) NewContext() (*Context, error) {
+	list := make([]PasswordHasher, 0, len(p.Hashers))
+
+	for _, algorithm := range p.Hashers {
+		h, err := p.Hasher(algorithm)
+
+		if err != nil {
+			return nil, err
+		}
+
+		list = append(list, h)
+	}
+
+	return NewContext(list...)
+}
+
+// UseProfile registers every built-in hasher configured with the profile
+// parameters, so MakePassword, CheckPassword and NeedsRehash
+// behave like the Django release.
+//
+// Hashers registered with the same algorithm are replaced.
+func UseProfile(p Profile) error {
+	for _, algorithm := range []string{
+		Argon2Hasher,
+		BCryptHasher,
+		BCryptSHA256Hasher,
+		CryptHasher,
+		MD5Hasher,
+		PBKDF2SHA1Hasher,
+		PBKDF2SHA256Hasher,
+		ScryptHasher,
+		SHA1Hasher,
+		UnsaltedMD5Hasher,
+		UnsaltedSHA1Hasher,
+	} {
+		h, err := p.Hasher(algorithm)
+
+		if err != nil {
+			return err
+		}
+
+		RegisterHasher(h)
+	}
+
+	return nil
+}
@@ -0,0 +1,153 @@
+package unchained
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestProfileMakePassword(t *testing.T) {
+	ctx, err := Django42.NewContext()
+
+	if err != nil {
+		t.Fatalf("NewContext error: %s", err)
+	}
+
+	encoded, err := ctx.MakePassword("admin", "", "default")
+
+	if err != nil {
+		t.Fatalf("Make password error: %s", err)
+	}
+
+	s := strings.Split(encoded, "$")
+
+	if s[0] != PBKDF2SHA256Hasher || s[1] != "600000" {
+		t.Fatalf("Encoded password does not use Django 4.2 defaults: %s", encoded)
+	}
+
+	if len(s[2]) != 22 {
+		t.Fatalf("Salt %s should have 22 characters.", s[2])
+	}
+
+	if ctx.NeedsRehash(encoded) {
+		t.Fatal("Password should not need rehash.")
+	}
+}
+
+func TestProfileNeedsRehash(t *testing.T) {
+	ctx, err := Django31.NewContext()
+
+	if err != nil {
+		t.Fatalf("NewContext error: %s", err)
+	}
+
+	encoded := "pbkdf2_sha256$216000$WZrFZhpl3wOU$XXhYHdRg/B2IRXX99GAejp8zUymgg5dEiJjtOksNUUo="
+
+	if ctx.NeedsRehash(encoded) {
+		t.Fatal("Password should not need rehash with Django 3.1.")
+	}
+
+	ctx, err = Django32.NewContext()
+
+	if err != nil {
+		t.Fatalf("NewContext error: %s", err)
+	}
+
+	if !ctx.NeedsRehash(encoded) {
+		t.Fatal("Password should need rehash with Django 3.2.")
+	}
+}
+
+func TestProfileHashers(t *testing.T) {
+	ctx, err := Django31.NewContext()
+
+	if err != nil {
+		t.Fatalf("NewContext error: %s", err)
+	}
+
+	if _, err := ctx.LookupHasher(ScryptHasher); err != ErrHasherNotConfigured {
+		t.Fatalf("Error %v is not %s.", err, ErrHasherNotConfigured)
+	}
+
+	ctx, err = Django40.NewContext()
+
+	if err != nil {
+		t.Fatalf("NewContext error: %s", err)
+	}
+
+	if _, err := ctx.LookupHasher(ScryptHasher); err != nil {
+		t.Fatalf("Lookup error: %s", err)
+	}
+}
+
+func TestProfileArgon2(t *testing.T) {
+	h, err := Django30.Hasher(Argon2Hasher)
+
+	if err != nil {
+		t.Fatalf("Hasher error: %s", err)
+	}
+
+	encoded, err := h.Encode("admin", "6qY4lfA15naU")
+
+	if err != nil {
+		t.Fatalf("Encode error: %s", err)
+	}
+
+	// Encoded by Django 3.0 with its default Argon2 parameters.
+	expected := "argon2$argon2i$v=19$m=512,t=2,p=2$NnFZNGxmQTE1bmFV$kPPGrqD6dnRllcQeksFN+w"
+
+	if encoded != expected {
+		t.Fatalf("Encoded password %s does not match %s.", encoded, expected)
+	}
+
+	if h.MustUpdate(expected) {
+		t.Fatal("Password should not need update with Django 3.0.")
+	}
+
+	ctx, err := Django30.NewContext()
+
+	if err != nil {
+		t.Fatalf("NewContext error: %s", err)
+	}
+
+	if valid, err := ctx.CheckPassword("admin", expected); !valid || err != nil {
+		t.Fatalf("Password should be valid, error: %v", err)
+	}
+}
+
+func TestProfileHashersNotShared(t *testing.T) {
+	p := Django30
+	p.Hashers[0] = ScryptHasher
+	defer func() { p.Hashers[0] = PBKDF2SHA256Hasher }()
+
+	if Django31.Hashers[0] != PBKDF2SHA256Hasher {
+		t.Fatal("Profiles should not share their hashers.")
+	}
+}
+
+func TestProfileInvalidHasher(t *testing.T) {
+	if _, err := Django52.Hasher("invalid"); err != ErrInvalidHasher {
+		t.Fatalf("Error %v is not %s.", err, ErrInvalidHasher)
+	}
+}
+
+func TestUseProfile(t *testing.T) {
+	defer saveHashers()()
+
+	if err := UseProfile(Django52); err != nil {
+		t.Fatalf("UseProfile error: %s", err)
+	}
+
+	encoded, err := MakePassword("admin", "", "default")
+
+	if err != nil {
+		t.Fatalf("Make password error: %s", err)
+	}
+
+	if !strings.HasPrefix(encoded, "pbkdf2_sha256$1000000$") {
+		t.Fatalf("Encoded password does not use Django 5.2 defaults: %s", encoded)
+	}
+
+	if !NeedsRehash("pbkdf2_sha256$216000$WZrFZhpl3wOU$XXhYHdRg/B2IRXX99GAejp8zUymgg5dEiJjtOksNUUo=") {
+		t.Fatal("Password should need rehash.")
+	}
+}
@@ -2,43 +2,119 @@ package unchained
 
 import (
 	crand "crypto/rand"
-	"math/big"
-	"math/rand"
+	"errors"
+	"io"
+	"unicode/utf8"
 )
 
 const (
 	allowedChars     = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
 	allowedCharsSize = len(allowedChars)
-	maxInt           = 1<<63 - 1
 )
 
-type source struct{}
+// ErrInvalidAlphabet is returned if the alphabet of a RandomStringGenerator
+// has more than 256 characters or characters that are not ASCII.
+var ErrInvalidAlphabet = errors.New("unchained: alphabet must have at most 256 ASCII characters")
 
-func (s *source) Int63() int64 {
-	return int64(s.Uint64() & ^uint64(1<<63))
+// SaltGenerator generates the random strings used as salts
+// and as unusable passwords.
+type SaltGenerator interface {
+	// RandomString returns a random string with length characters.
+	RandomString(length int) (string, error)
 }
 
-func (s *source) Uint64() uint64 {
-	i, err := crand.Int(crand.Reader, big.NewInt(maxInt))
+// RandomStringGenerator is a SaltGenerator that picks characters
+// of Alphabet uniformly, using the random bytes read from Reader.
+type RandomStringGenerator struct {
+	// Source of random bytes, crypto/rand.Reader if nil.
+	Reader io.Reader
+	// Characters used in the strings, letters and digits if empty.
+	// At most 256 ASCII characters.
+	Alphabet string
+}
 
-	if err != nil {
-		panic(err)
+// NewRandomStringGenerator returns a RandomStringGenerator
+// that reads from r and uses the characters of alphabet.
+func NewRandomStringGenerator(r io.Reader, alphabet string) *RandomStringGenerator {
+	return &RandomStringGenerator{Reader: r, Alphabet: alphabet}
+}
+
+// defaultGenerator uses crypto/rand and allowedChars, like Django.
+var defaultGenerator = &RandomStringGenerator{}
+
+// orDefault returns g, or the generator based on crypto/rand if g is nil.
+func orDefault(g SaltGenerator) SaltGenerator {
+	if g == nil {
+		return defaultGenerator
+	}
+
+	return g
+}
+
+// RandomString returns a random string with length characters,
+// or the error returned by Reader.
+func (g *RandomStringGenerator) RandomString(length int) (string, error) {
+	r := g.Reader
+
+	if r == nil {
+		r = crand.Reader
+	}
+
+	alphabet := g.Alphabet
+
+	if alphabet == "" {
+		alphabet = allowedChars
+	} else if len(alphabet) > 256 || !isASCII(alphabet) {
+		return "", ErrInvalidAlphabet
+	}
+
+	n := len(alphabet)
+
+	// Bytes from limit up are rejected, so that every
+	// character of the alphabet is equally likely.
+	limit := 256 - 256%n
+	b := make([]byte, length)
+
+	// Random bytes are read into the unfilled part of b, in batches,
+	// and the accepted ones are moved to the front.
+	for i := 0; i < length; {
+		if _, err := io.ReadFull(r, b[i:]); err != nil {
+			return "", err
+		}
+
+		for _, c := range b[i:] {
+			if int(c) < limit {
+				b[i] = alphabet[int(c)%n]
+				i++
+			}
+		}
 	}
 
-	return i.Uint64()
+	return string(b), nil
 }
 
-func (s *source) Seed(seed int64) {}
+// isASCII returns true if s only has ASCII characters,
+// so that each byte of s is a character.
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+
+	return true
+}
 
 // GetRandomString returns a securely generated random string.
+//
+// It panics if crypto/rand fails, use RandomStringGenerator
+// to handle the error instead.
 func GetRandomString(length int) string {
-	b := make([]byte, length)
-	rnd := rand.New(&source{})
+	s, err := defaultGenerator.RandomString(length)
 
-	for i := range b {
-		c := rnd.Intn(allowedCharsSize)
-		b[i] = allowedChars[c]
+	if err != nil {
+		panic(err)
 	}
 
-	return string(b)
+	return s
 }
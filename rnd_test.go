@@ -0,0 +1,115 @@
+package unchained
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// errReader is an io.Reader that always fails with err.
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read(p []byte) (int, error) {
+	return 0, r.err
+}
+
+func TestGetRandomString(t *testing.T) {
+	s := GetRandomString(40)
+
+	if len(s) != 40 {
+		t.Fatalf("Length %d is not 40.", len(s))
+	}
+
+	for _, c := range s {
+		if !strings.ContainsRune(allowedChars, c) {
+			t.Fatalf("Character %q is not allowed.", c)
+		}
+	}
+}
+
+func TestRandomStringGeneratorDeterministic(t *testing.T) {
+	g := NewRandomStringGenerator(bytes.NewReader(make([]byte, 64)), "xyz")
+	s, err := g.RandomString(8)
+
+	if err != nil {
+		t.Fatalf("RandomString error: %s", err)
+	}
+
+	if s != "xxxxxxxx" {
+		t.Fatalf("Random string %s is not xxxxxxxx.", s)
+	}
+}
+
+func TestRandomStringGeneratorError(t *testing.T) {
+	errEntropy := errors.New("no entropy")
+	g := NewRandomStringGenerator(errReader{errEntropy}, "")
+
+	if _, err := g.RandomString(12); err != errEntropy {
+		t.Fatalf("Error %v is not %s.", err, errEntropy)
+	}
+}
+
+func TestRandomStringGeneratorRejection(t *testing.T) {
+	// 255 is rejected with an alphabet of 3 characters.
+	g := NewRandomStringGenerator(bytes.NewReader([]byte{255, 1, 255, 2, 255, 255, 3}), "xyz")
+	s, err := g.RandomString(3)
+
+	if err != nil {
+		t.Fatalf("RandomString error: %s", err)
+	}
+
+	if s != "yzx" {
+		t.Fatalf("Random string %s is not yzx.", s)
+	}
+}
+
+func TestRandomStringGeneratorInvalidAlphabet(t *testing.T) {
+	for _, alphabet := range []string{strings.Repeat("a", 257), "αβγ"} {
+		g := NewRandomStringGenerator(nil, alphabet)
+
+		if _, err := g.RandomString(12); err != ErrInvalidAlphabet {
+			t.Fatalf("Error %v is not %s.", err, ErrInvalidAlphabet)
+		}
+	}
+}
+
+func TestRandomStringGeneratorDistribution(t *testing.T) {
+	s, err := defaultGenerator.RandomString(allowedCharsSize * 1000)
+
+	if err != nil {
+		t.Fatalf("RandomString error: %s", err)
+	}
+
+	counts := make(map[rune]int)
+
+	for _, c := range s {
+		counts[c]++
+	}
+
+	if len(counts) != allowedCharsSize {
+		t.Fatalf("Got %d distinct characters, expected %d.", len(counts), allowedCharsSize)
+	}
+
+	// Each character is expected 1000 times, the bounds are loose
+	// enough to never fail with an unbiased generator.
+	for c, n := range counts {
+		if n < 800 || n > 1200 {
+			t.Fatalf("Character %q appears %d times.", c, n)
+		}
+	}
+}
+
+func BenchmarkGetRandomString12(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		GetRandomString(12)
+	}
+}
+
+func BenchmarkGetRandomString40(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		GetRandomString(40)
+	}
+}
@@ -0,0 +1,2 @@
+// Package scrypt implements a Django compatible scrypt algorithm.
+package scrypt
@@ -0,0 +1,222 @@
+package scrypt
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/alexandrevicenzi/unchained/internal/entropy"
+	"github.com/alexandrevicenzi/unchained/internal/hasherr"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Errors returned by ScryptPasswordHasher.
+//
+// Errors about a component of an encoded password are wrapped
+// in an unchained.HashError, match them with errors.Is.
+var (
+	ErrHashComponentUnreadable = hasherr.New(hasherr.ErrMalformedHash, "unchained/scrypt: unreadable component in hashed password")
+	ErrHashComponentMismatch   = hasherr.New(hasherr.ErrMalformedHash, "unchained/scrypt: hashed password components mismatch")
+	ErrAlgorithmMismatch       = hasherr.New(hasherr.ErrUnsupportedAlgorithm, "unchained/scrypt: algorithm mismatch")
+	ErrSaltContainsDollarSing  = hasherr.New(hasherr.ErrInvalidSalt, "unchained/scrypt: salt contains dollar sign ($)")
+	ErrSaltIsEmpty             = hasherr.New(hasherr.ErrInvalidSalt, "unchained/scrypt: salt is empty")
+	ErrMaxMemoryExceeded       = hasherr.New(hasherr.ErrLimitExceeded, "unchained/scrypt: parameters exceed maximum memory")
+	ErrInvalidParameters       = hasherr.New(hasherr.ErrInvalidParameters, "unchained/scrypt: invalid parameters")
+	ErrPasswordTooLong         = hasherr.New(hasherr.ErrPasswordTooLong, "unchained/scrypt: password too long")
+)
+
+// DefaultMaxMemory is the memory limit used when MaxMemory is zero,
+// the same default used by OpenSSL and therefore by Django.
+const DefaultMaxMemory = 32 * 1024 * 1024
+
+// DecodedHash holds the components of a scrypt encoded password.
+type DecodedHash struct {
+	// Algorithm identifier.
+	Algorithm string
+	// CPU/memory cost parameter (N).
+	WorkFactor int
+	// Salt used to encode the password.
+	Salt string
+	// Block size parameter (r).
+	BlockSize int
+	// Parallelization parameter (p).
+	Parallelism int
+	// Base64 encoded hash.
+	Hash string
+}
+
+// ScryptPasswordHasher implements scrypt password hasher.
+type ScryptPasswordHasher struct {
+	// Algorithm identifier.
+	Algorithm string
+	// Defines the CPU/memory cost parameter (N), must be a power of two.
+	WorkFactor int
+	// Defines the block size parameter (r).
+	BlockSize int
+	// Defines the parallelization parameter (p).
+	Parallelism int
+	// Defines the maximum memory in bytes, zero means DefaultMaxMemory.
+	MaxMemory int
+	// Defines the length of the hash in bytes.
+	Size int
+	// Defines the maximum length of passwords in bytes, zero means no limit.
+	MaxPasswordLength int
+	// Defines the entropy of new salts in bits, zero means unchained.DefaultSaltEntropy.
+	// Unlike the other hashers, encoded digests whose salt has less entropy
+	// do not need an update, as in Django.
+	SaltEntropy int
+}
+
+// key derives the hash, checking the memory required by the parameters first.
+func (h *ScryptPasswordHasher) key(password, salt string, n, r, p int) ([]byte, error) {
+	maxmem := h.MaxMemory
+
+	if maxmem <= 0 {
+		maxmem = DefaultMaxMemory
+	}
+
+	// "golang.org/x/crypto/scrypt" divides by r and p.
+	if n <= 1 || r < 1 || p < 1 {
+		return nil, ErrInvalidParameters
+	}
+
+	// Same estimate used by OpenSSL: 128 * r * (n + 2 + p) bytes.
+	if uint64(n)+2+uint64(p) > uint64(maxmem)/128/uint64(r) {
+		return nil, ErrMaxMemoryExceeded
+	}
+
+	hash, err := scrypt.Key([]byte(password), []byte(salt), n, r, p, h.Size)
+
+	if err != nil {
+		return nil, ErrInvalidParameters
+	}
+
+	return hash, nil
+}
+
+// Encode turns a plain-text password into a hash.
+func (h *ScryptPasswordHasher) Encode(password string, salt string) (string, error) {
+	if h.MaxPasswordLength > 0 && len(password) > h.MaxPasswordLength {
+		return "", ErrPasswordTooLong
+	}
+
+	if len(salt) == 0 {
+		return "", hasherr.Wrap(h.Algorithm, "salt", ErrSaltIsEmpty)
+	}
+
+	if strings.Contains(salt, "$") {
+		return "", hasherr.Wrap(h.Algorithm, "salt", ErrSaltContainsDollarSing)
+	}
+
+	hash, err := h.key(password, salt, h.WorkFactor, h.BlockSize, h.Parallelism)
+
+	if err != nil {
+		return "", err
+	}
+
+	b64Hash := base64.StdEncoding.EncodeToString(hash)
+
+	return fmt.Sprintf("%s$%d$%s$%d$%d$%s",
+		h.Algorithm,
+		h.WorkFactor,
+		salt,
+		h.BlockSize,
+		h.Parallelism,
+		b64Hash,
+	), nil
+}
+
+// Decode splits the encoded digest into its components.
+func (h *ScryptPasswordHasher) Decode(encoded string) (*DecodedHash, error) {
+	s := strings.Split(encoded, "$")
+
+	if len(s) != 6 {
+		return nil, ErrHashComponentMismatch
+	}
+
+	algorithm, workFactor, salt, blockSize, parallelism, hash := s[0], s[1], s[2], s[3], s[4], s[5]
+
+	if algorithm != h.Algorithm {
+		return nil, hasherr.Wrap(h.Algorithm, "algorithm", ErrAlgorithmMismatch)
+	}
+
+	d := &DecodedHash{
+		Algorithm: algorithm,
+		Salt:      salt,
+		Hash:      hash,
+	}
+
+	var err error
+
+	if d.WorkFactor, err = strconv.Atoi(workFactor); err != nil {
+		return nil, hasherr.Wrap(h.Algorithm, "work_factor", ErrHashComponentUnreadable)
+	}
+
+	if d.BlockSize, err = strconv.Atoi(blockSize); err != nil {
+		return nil, hasherr.Wrap(h.Algorithm, "block_size", ErrHashComponentUnreadable)
+	}
+
+	if d.Parallelism, err = strconv.Atoi(parallelism); err != nil {
+		return nil, hasherr.Wrap(h.Algorithm, "parallelism", ErrHashComponentUnreadable)
+	}
+
+	return d, nil
+}
+
+// Verify if a plain-text password matches the encoded digest.
+func (h *ScryptPasswordHasher) Verify(password string, encoded string) (bool, error) {
+	if h.MaxPasswordLength > 0 && len(password) > h.MaxPasswordLength {
+		return false, ErrPasswordTooLong
+	}
+
+	d, err := h.Decode(encoded)
+
+	if err != nil {
+		return false, err
+	}
+
+	bHash, err := base64.StdEncoding.DecodeString(d.Hash)
+
+	if err != nil || len(bHash) == 0 {
+		return false, hasherr.Wrap(h.Algorithm, "hash", ErrHashComponentUnreadable)
+	}
+
+	newHash, err := h.key(password, d.Salt, d.WorkFactor, d.BlockSize, d.Parallelism)
+
+	if err != nil {
+		return false, err
+	}
+
+	return subtle.ConstantTimeCompare(bHash, newHash) == 1, nil
+}
+
+// MustUpdate returns true if the encoded digest uses different
+// work factor, block size or parallelism than the hasher.
+func (h *ScryptPasswordHasher) MustUpdate(encoded string) bool {
+	d, err := h.Decode(encoded)
+
+	if err != nil {
+		return false
+	}
+
+	return d.WorkFactor != h.WorkFactor ||
+		d.BlockSize != h.BlockSize ||
+		d.Parallelism != h.Parallelism
+}
+
+// NewScryptPasswordHasher secures password hashing using the scrypt algorithm.
+//
+// Configured with the same parameters as Django.
+func NewScryptPasswordHasher() *ScryptPasswordHasher {
+	return &ScryptPasswordHasher{
+		Algorithm:   "scrypt",
+		WorkFactor:  1 << 14,
+		BlockSize:   8,
+		Parallelism: 1,
+		MaxMemory:   0,
+		Size:        64,
+		SaltEntropy: entropy.Default,
+	}
+}
@@ -0,0 +1,21 @@
+//go:build go1.18
+// +build go1.18
+
+package scrypt
+
+import "testing"
+
+func FuzzScryptVerify(f *testing.F) {
+	f.Add("scrypt$16$salt$1$1$Y2JkZWY=")
+	f.Add("scrypt$16384$salt$0$0$Y2JkZWY=")
+	f.Add("scrypt$3$salt$8$1$")
+
+	f.Fuzz(func(t *testing.T, encoded string) {
+		h := NewScryptPasswordHasher()
+		h.MaxMemory = 1024 * 1024
+
+		h.Decode(encoded)
+		h.Verify("admin", encoded)
+		h.MustUpdate(encoded)
+	})
+}
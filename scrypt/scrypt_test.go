@@ -0,0 +1,126 @@
+package scrypt
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestScryptEncode1(t *testing.T) {
+	encoded, err := NewScryptPasswordHasher().Encode("admin", "WZrFZhpl3wOU")
+
+	if err != nil {
+		t.Fatalf("Encode error: %s", err)
+	}
+
+	expected := "scrypt$16384$WZrFZhpl3wOU$8$1$MBWs+8N/mw8Zitnc1lotoVyDezPrF/fZISvRUVuChA7c0iwIhQLNvJO1yHb7nMZuzJxdpl3Y3NEWBsfwLrphTQ=="
+
+	if encoded != expected {
+		t.Fatalf("Encoded hash %s does not match %s.", encoded, expected)
+	}
+}
+
+func TestScryptEncode2(t *testing.T) {
+	encoded, err := NewScryptPasswordHasher().Encode("this-is-my-password", "ITqksnfwCKZr")
+
+	if err != nil {
+		t.Fatalf("Encode error: %s", err)
+	}
+
+	expected := "scrypt$16384$ITqksnfwCKZr$8$1$GMnhxndVCREjzQxXJtlMMo9seYbIOLrl5E8/mM1XnJU6c9Rpxl4vRyQHa1IXmyip80pdm8Rx3HcOgTcdXz6pQg=="
+
+	if encoded != expected {
+		t.Fatalf("Encoded hash %s does not match %s.", encoded, expected)
+	}
+}
+
+func TestScryptEncodeMaxMemory(t *testing.T) {
+	h := NewScryptPasswordHasher()
+	h.WorkFactor = 1 << 15
+	h.Parallelism = 2
+
+	_, err := h.Encode("Th1S1sMYp4ssw0rd", "vM98pB74e18T")
+
+	if !errors.Is(err, ErrMaxMemoryExceeded) {
+		t.Fatalf("Error %v is not %s.", err, ErrMaxMemoryExceeded)
+	}
+
+	h.MaxMemory = 64 * 1024 * 1024
+
+	encoded, err := h.Encode("Th1S1sMYp4ssw0rd", "vM98pB74e18T")
+
+	if err != nil {
+		t.Fatalf("Encode error: %s", err)
+	}
+
+	expected := "scrypt$32768$vM98pB74e18T$8$2$mRSDuY6rcU9t5Fugl9ZPZHBK5Q8SzjEfB2FzvWB+n7OKLbb6brilOZQxoko6TGSyptbRQAFFTFNQQmuAHrYfMA=="
+
+	if encoded != expected {
+		t.Fatalf("Encoded hash %s does not match %s.", encoded, expected)
+	}
+}
+
+func TestScryptVerify(t *testing.T) {
+	valid, err := NewScryptPasswordHasher().Verify("admin", "scrypt$16384$WZrFZhpl3wOU$8$1$MBWs+8N/mw8Zitnc1lotoVyDezPrF/fZISvRUVuChA7c0iwIhQLNvJO1yHb7nMZuzJxdpl3Y3NEWBsfwLrphTQ==")
+
+	if err != nil {
+		t.Fatalf("Verify error: %s", err)
+	}
+
+	if !valid {
+		t.Fatal("Password should be valid.")
+	}
+}
+
+func TestScryptVerifyInvalidPassword(t *testing.T) {
+	valid, err := NewScryptPasswordHasher().Verify("wrongpassword", "scrypt$16384$WZrFZhpl3wOU$8$1$MBWs+8N/mw8Zitnc1lotoVyDezPrF/fZISvRUVuChA7c0iwIhQLNvJO1yHb7nMZuzJxdpl3Y3NEWBsfwLrphTQ==")
+
+	if err != nil {
+		t.Fatalf("Verify error: %s", err)
+	}
+
+	if valid {
+		t.Fatal("Password should not be valid.")
+	}
+}
+
+func TestScryptDecode(t *testing.T) {
+	d, err := NewScryptPasswordHasher().Decode("scrypt$16384$WZrFZhpl3wOU$8$1$MBWs+8N/mw8Zitnc1lotoVyDezPrF/fZISvRUVuChA7c0iwIhQLNvJO1yHb7nMZuzJxdpl3Y3NEWBsfwLrphTQ==")
+
+	if err != nil {
+		t.Fatalf("Decode error: %s", err)
+	}
+
+	if d.WorkFactor != 16384 || d.Salt != "WZrFZhpl3wOU" || d.BlockSize != 8 || d.Parallelism != 1 {
+		t.Fatalf("Decoded hash %+v does not match.", d)
+	}
+}
+
+func TestScryptMustUpdate(t *testing.T) {
+	h := NewScryptPasswordHasher()
+
+	// Like Django, the salt is not checked.
+	if h.MustUpdate("scrypt$16384$WZrFZhpl3wOU$8$1$MBWs+8N/mw8Zitnc1lotoVyDezPrF/fZISvRUVuChA7c0iwIhQLNvJO1yHb7nMZuzJxdpl3Y3NEWBsfwLrphTQ==") {
+		t.Fatal("Hash with same parameters should not be updated.")
+	}
+
+	h.WorkFactor = 1 << 15
+
+	if !h.MustUpdate("scrypt$16384$WZrFZhpl3wOU$8$1$MBWs+8N/mw8Zitnc1lotoVyDezPrF/fZISvRUVuChA7c0iwIhQLNvJO1yHb7nMZuzJxdpl3Y3NEWBsfwLrphTQ==") {
+		t.Fatal("Hash with different work factor should be updated.")
+	}
+}
+
+func TestScryptVerifyInvalidParameters(t *testing.T) {
+	for _, encoded := range []string{
+		"scrypt$16384$salt$0$1$Y2JkZWY=",
+		"scrypt$16384$salt$8$0$Y2JkZWY=",
+		"scrypt$1$salt$8$1$Y2JkZWY=",
+		"scrypt$1000$salt$8$1$Y2JkZWY=",
+	} {
+		_, err := NewScryptPasswordHasher().Verify("admin", encoded)
+
+		if !errors.Is(err, ErrInvalidParameters) {
+			t.Fatalf("Error %v is not %s for %s.", err, ErrInvalidParameters, encoded)
+		}
+	}
+}
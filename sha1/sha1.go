@@ -3,37 +3,62 @@ package sha1
 import (
 	"crypto/hmac"
 	"crypto/sha1"
-	"errors"
 	"fmt"
 	"io"
 	"strings"
+
+	"github.com/alexandrevicenzi/unchained/internal/entropy"
+	"github.com/alexandrevicenzi/unchained/internal/hasherr"
 )
 
 // Errors returned by SHA1PasswordHasher.
+//
+// Errors about a component of an encoded password are wrapped
+// in an unchained.HashError, match them with errors.Is.
 var (
-	ErrHashComponentMismatch  = errors.New("unchained/sha1: hashed password components mismatch")
-	ErrAlgorithmMismatch      = errors.New("unchained/sha1: algorithm mismatch")
-	ErrSaltContainsDollarSing = errors.New("unchained/sha1: salt contains dollar sign ($)")
-	ErrSaltIsEmpty            = errors.New("unchained/sha1: salt is empty")
+	ErrHashComponentMismatch  = hasherr.New(hasherr.ErrMalformedHash, "unchained/sha1: hashed password components mismatch")
+	ErrAlgorithmMismatch      = hasherr.New(hasherr.ErrUnsupportedAlgorithm, "unchained/sha1: algorithm mismatch")
+	ErrSaltContainsDollarSing = hasherr.New(hasherr.ErrInvalidSalt, "unchained/sha1: salt contains dollar sign ($)")
+	ErrSaltIsEmpty            = hasherr.New(hasherr.ErrInvalidSalt, "unchained/sha1: salt is empty")
+	ErrPasswordTooLong        = hasherr.New(hasherr.ErrPasswordTooLong, "unchained/sha1: password too long")
 )
 
+// DecodedHash holds the components of a SHA1 encoded password.
+type DecodedHash struct {
+	// Algorithm identifier.
+	Algorithm string
+	// Salt used to encode the password.
+	Salt string
+	// Hex encoded hash.
+	Hash string
+}
+
 // SHA1PasswordHasher implements Salted SHA1 password hasher.
 type SHA1PasswordHasher struct {
 	// Algorithm identifier.
 	Algorithm string
 	// Use salt to encode.
 	Salted bool
+	// Defines the maximum length of passwords in bytes, zero means no limit.
+	MaxPasswordLength int
+	// Defines the minimum entropy of salts in bits, zero means unchained.DefaultSaltEntropy.
+	// Salted encoded digests whose salt has less entropy must be updated.
+	SaltEntropy int
 }
 
 // Encode turns a plain-text password into a hash.
 func (h *SHA1PasswordHasher) Encode(password string, salt string) (string, error) {
+	if h.MaxPasswordLength > 0 && len(password) > h.MaxPasswordLength {
+		return "", ErrPasswordTooLong
+	}
+
 	if h.Salted {
 		if len(salt) == 0 {
-			return "", ErrSaltIsEmpty
+			return "", hasherr.Wrap(h.Algorithm, "salt", ErrSaltIsEmpty)
 		}
 
 		if strings.Contains(salt, "$") {
-			return "", ErrSaltContainsDollarSing
+			return "", hasherr.Wrap(h.Algorithm, "salt", ErrSaltContainsDollarSing)
 		}
 	} else {
 		salt = ""
@@ -50,21 +75,36 @@ func (h *SHA1PasswordHasher) Encode(password string, salt string) (string, error
 	return fmt.Sprintf("sha1$%s$%x", salt, hasher.Sum(nil)), nil
 }
 
-// Verify if a plain-text password matches the encoded digest.
-func (h *SHA1PasswordHasher) Verify(password string, encoded string) (bool, error) {
+// Decode splits the encoded digest into its components.
+func (h *SHA1PasswordHasher) Decode(encoded string) (*DecodedHash, error) {
 	s := strings.Split(encoded, "$")
 
 	if len(s) != 3 {
-		return false, ErrHashComponentMismatch
+		return nil, ErrHashComponentMismatch
 	}
 
-	algorithm, salt := s[0], s[1]
+	algorithm, salt, hash := s[0], s[1], s[2]
 
 	if algorithm != "sha1" {
-		return false, ErrAlgorithmMismatch
+		return nil, hasherr.Wrap(h.Algorithm, "algorithm", ErrAlgorithmMismatch)
 	}
 
-	newencoded, err := h.Encode(password, salt)
+	return &DecodedHash{
+		Algorithm: h.Algorithm,
+		Salt:      salt,
+		Hash:      hash,
+	}, nil
+}
+
+// Verify if a plain-text password matches the encoded digest.
+func (h *SHA1PasswordHasher) Verify(password string, encoded string) (bool, error) {
+	d, err := h.Decode(encoded)
+
+	if err != nil {
+		return false, err
+	}
+
+	newencoded, err := h.Encode(password, d.Salt)
 
 	if err != nil {
 		return false, err
@@ -73,6 +113,22 @@ func (h *SHA1PasswordHasher) Verify(password string, encoded string) (bool, erro
 	return hmac.Equal([]byte(newencoded), []byte(encoded)), nil
 }
 
+// MustUpdate returns true if the hasher is salted and the salt
+// of the encoded digest has less entropy than SaltEntropy.
+func (h *SHA1PasswordHasher) MustUpdate(encoded string) bool {
+	if !h.Salted {
+		return false
+	}
+
+	d, err := h.Decode(encoded)
+
+	if err != nil {
+		return false
+	}
+
+	return !entropy.Enough(d.Salt, h.SaltEntropy)
+}
+
 // NewUnsaltedSHA1PasswordHasher is an incredibly insecure algorithm
 // that should never be used. It stores unsalted SHA1 hashes with an empty salt.
 //
@@ -88,7 +144,8 @@ func NewUnsaltedSHA1PasswordHasher() *SHA1PasswordHasher {
 // NewSHA1PasswordHasher secures password hashing using Salted SHA1 algorithm (not recommended).
 func NewSHA1PasswordHasher() *SHA1PasswordHasher {
 	return &SHA1PasswordHasher{
-		Algorithm: "sha1",
-		Salted:    true,
+		Algorithm:   "sha1",
+		Salted:      true,
+		SaltEntropy: entropy.Default,
 	}
 }
@@ -0,0 +1,18 @@
+//go:build go1.18
+// +build go1.18
+
+package sha1
+
+import "testing"
+
+func FuzzSHA1Verify(f *testing.F) {
+	f.Add("sha1$seasalt$cff36ea83f5706ce9aa7454e63e431fc726b2dc8")
+	f.Add("sha1$$d033e22ae348aeb5660fc2140aec35850c4da997")
+
+	f.Fuzz(func(t *testing.T, encoded string) {
+		for _, h := range []*SHA1PasswordHasher{NewSHA1PasswordHasher(), NewUnsaltedSHA1PasswordHasher()} {
+			h.Decode(encoded)
+			h.Verify("admin", encoded)
+		}
+	})
+}
@@ -55,3 +55,33 @@ func TestSHA1PasswordVerify(t *testing.T) {
 		t.Fatal("Password should be valid.")
 	}
 }
+
+func TestSHA1PasswordDecode(t *testing.T) {
+	d, err := NewSHA1PasswordHasher().Decode("sha1$FJkZbdAmXSDF$972db6461472a5345bab667d0255d120e06a3415")
+
+	if err != nil {
+		t.Fatalf("Decode error: %s", err)
+	}
+
+	if d.Algorithm != "sha1" || d.Salt != "FJkZbdAmXSDF" || d.Hash != "972db6461472a5345bab667d0255d120e06a3415" {
+		t.Fatalf("Decoded hash %+v does not match.", d)
+	}
+}
+
+func TestSHA1PasswordMustUpdate(t *testing.T) {
+	h := NewSHA1PasswordHasher()
+
+	if h.MustUpdate("sha1$FJkZbdAmXSDF$972db6461472a5345bab667d0255d120e06a3415") {
+		t.Fatal("Hash with enough salt entropy should not be updated.")
+	}
+
+	h.SaltEntropy = 128
+
+	if !h.MustUpdate("sha1$FJkZbdAmXSDF$972db6461472a5345bab667d0255d120e06a3415") {
+		t.Fatal("Hash with less salt entropy should be updated.")
+	}
+
+	if NewUnsaltedSHA1PasswordHasher().MustUpdate("sha1$$d033e22ae348aeb5660fc2140aec35850c4da997") {
+		t.Fatal("Unsalted hash should not be updated.")
+	}
+}
@@ -1,14 +1,12 @@
 package unchained
 
 import (
+	"context"
 	"errors"
 	"strings"
 
-	"github.com/alexandrevicenzi/unchained/argon2"
-	"github.com/alexandrevicenzi/unchained/bcrypt"
-	"github.com/alexandrevicenzi/unchained/md5"
-	"github.com/alexandrevicenzi/unchained/pbkdf2"
-	"github.com/alexandrevicenzi/unchained/sha1"
+	"github.com/alexandrevicenzi/unchained/internal/entropy"
+	"github.com/alexandrevicenzi/unchained/internal/hasherr"
 )
 
 // Django hasher identifiers.
@@ -20,6 +18,7 @@ const (
 	MD5Hasher          = "md5"
 	PBKDF2SHA1Hasher   = "pbkdf2_sha1"
 	PBKDF2SHA256Hasher = "pbkdf2_sha256"
+	ScryptHasher       = "scrypt"
 	SHA1Hasher         = "sha1"
 	UnsaltedMD5Hasher  = "unsalted_md5"
 	UnsaltedSHA1Hasher = "unsalted_sha1"
@@ -32,19 +31,59 @@ const (
 	UnusablePasswordSuffixLength = 40
 	// The default hasher used in Django.
 	DefaultHasher = PBKDF2SHA256Hasher
-	// The default salt size used in Django.
+	// The default salt entropy in bits, of the salts used in Django
+	// before 3.2, also used by the hashers whose SaltEntropy is zero.
+	// Django 3.2 and later use 128 bits, see UseProfile.
+	DefaultSaltEntropy = entropy.Default
+	// The default salt size used in Django, enough for DefaultSaltEntropy.
 	DefaultSaltSize = 12
+	// The default maximum password length in bytes,
+	// the same limit Django used to mitigate denial of service.
+	DefaultMaxPasswordLength = 4096
 )
 
+// Error categories shared by all hashers, to be used with errors.Is.
+//
+// Errors about a component of an encoded password are a *HashError
+// that records the algorithm and the failing component.
+var (
+	// ErrMalformedHash is the category of encoded passwords that cannot be parsed.
+	ErrMalformedHash = hasherr.ErrMalformedHash
+	// ErrUnsupportedAlgorithm is the category of unknown or unavailable algorithms.
+	ErrUnsupportedAlgorithm = hasherr.ErrUnsupportedAlgorithm
+	// ErrUnsupportedVersion is the category of unsupported algorithm versions.
+	ErrUnsupportedVersion = hasherr.ErrUnsupportedVersion
+	// ErrInvalidParameters is the category of invalid work factors.
+	ErrInvalidParameters = hasherr.ErrInvalidParameters
+	// ErrInvalidSalt is the category of invalid salts.
+	ErrInvalidSalt = hasherr.ErrInvalidSalt
+	// ErrLimitExceeded is the category of encoded passwords
+	// whose parameters exceed the verification limits.
+	ErrLimitExceeded = hasherr.ErrLimitExceeded
+	// ErrPasswordTooLong is the category of passwords
+	// longer than the maximum password length.
+	ErrPasswordTooLong = hasherr.ErrPasswordTooLong
+)
+
+// HashError records the algorithm and the component
+// of an encoded password that caused an error.
+//
+// Use errors.As to retrieve it and errors.Is to check its category.
+type HashError = hasherr.HashError
+
 var (
 	// ErrInvalidHasher is returned if the hasher is invalid or unknown.
-	ErrInvalidHasher = errors.New("unchained: invalid hasher")
+	ErrInvalidHasher = hasherr.New(ErrUnsupportedAlgorithm, "unchained: invalid hasher")
 	// ErrHasherNotImplemented is returned if the hasher is not implemented.
-	ErrHasherNotImplemented = errors.New("unchained: hasher not implemented")
+	ErrHasherNotImplemented = hasherr.New(ErrUnsupportedAlgorithm, "unchained: hasher not implemented")
+	// ErrHasherNotConfigured is returned if the hasher is not part of a Context.
+	ErrHasherNotConfigured = hasherr.New(ErrUnsupportedAlgorithm, "unchained: hasher not configured")
+	// ErrNoHashers is returned if a Context is created without hashers.
+	ErrNoHashers = errors.New("unchained: no hashers configured")
 )
 
 // IsValidHasher returns true if the hasher
-// is supported by Django, or false otherwise.
+// is supported by Django or registered, or false otherwise.
 func IsValidHasher(hasher string) bool {
 	switch hasher {
 	case
@@ -55,13 +94,14 @@ func IsValidHasher(hasher string) bool {
 		MD5Hasher,
 		PBKDF2SHA1Hasher,
 		PBKDF2SHA256Hasher,
+		ScryptHasher,
 		SHA1Hasher,
 		UnsaltedMD5Hasher,
 		UnsaltedSHA1Hasher:
 		return true
 	}
 
-	return false
+	return isRegistered(hasher)
 }
 
 // IsWeakHasher returns true if the hasher
@@ -81,88 +121,259 @@ func IsWeakHasher(hasher string) bool {
 }
 
 // IsHasherImplemented returns true if the hasher
-// is implemented in this library, or false otherwise.
+// is implemented in this library or registered, or false otherwise.
 func IsHasherImplemented(hasher string) bool {
-	switch hasher {
-	case
-		Argon2Hasher,
-		BCryptHasher,
-		BCryptSHA256Hasher,
-		// CryptHasher,
-		MD5Hasher,
-		PBKDF2SHA1Hasher,
-		PBKDF2SHA256Hasher,
-		SHA1Hasher,
-		UnsaltedMD5Hasher,
-		UnsaltedSHA1Hasher:
-		return true
-	}
-
-	return false
+	return isRegistered(hasher)
 }
 
 // IdentifyHasher returns the hasher used in the encoded password.
 func IdentifyHasher(encoded string) string {
-	size := len(encoded)
+	return identifyHasher(encoded, registeredHashers())
+}
+
+// IsPasswordUsable returns true if encoded password
+// is usable, or false otherwise.
+func IsPasswordUsable(encoded string) bool {
+	return encoded != "" && !strings.HasPrefix(encoded, UnusablePasswordPrefix)
+}
+
+// NeedsRehash returns true if the encoded password should be encoded again,
+// because it does not use the default hasher or its current settings.
+func NeedsRehash(encoded string) bool {
+	preferred, err := LookupHasher("default")
 
-	if size == 32 && !strings.Contains(encoded, "$") {
-		return UnsaltedMD5Hasher
+	if err != nil {
+		return false
 	}
 
-	if size == 37 && strings.HasPrefix(encoded, "md5$$") {
-		return UnsaltedMD5Hasher
+	return needsRehash(encoded, IdentifyHasher(encoded), preferred)
+}
+
+func needsRehash(encoded, hasher string, preferred PasswordHasher) bool {
+	if !IsPasswordUsable(encoded) {
+		return false
 	}
 
-	if size == 46 && strings.HasPrefix(encoded, "sha1$$") {
-		return UnsaltedSHA1Hasher
+	return hasher != preferred.Algorithm() || preferred.MustUpdate(encoded)
+}
+
+// Decode splits the encoded password into its components,
+// using the hasher that produced it.
+func Decode(encoded string) (*DecodedHash, error) {
+	return decode(registry{}, encoded)
+}
+
+// SafeSummary decodes the encoded password and masks its salt and hash,
+// so the result can be displayed, like Django's safe_summary.
+func SafeSummary(encoded string) (*DecodedHash, error) {
+	return safeSummary(registry{}, encoded)
+}
+
+// SaltEntropy returns the entropy in bits of the salt of the encoded
+// password, computed like Django as if the salt was made of letters and digits.
+//
+// Hashers other than scrypt report encoded passwords whose salt has less entropy
+// than their SaltEntropy as must update.
+func SaltEntropy(encoded string) (float64, error) {
+	return saltEntropy(registry{}, encoded)
+}
+
+func decode(src hasherSource, encoded string) (*DecodedHash, error) {
+	h, err := src.LookupHasher(src.IdentifyHasher(encoded))
+
+	if err != nil {
+		return nil, err
 	}
 
-	return strings.SplitN(encoded, "$", 2)[0]
+	return h.Decode(encoded)
 }
 
-// IsPasswordUsable returns true if encoded password
-// is usable, or false otherwise.
-func IsPasswordUsable(encoded string) bool {
-	return encoded != "" && !strings.HasPrefix(encoded, UnusablePasswordPrefix)
+func saltEntropy(src hasherSource, encoded string) (float64, error) {
+	d, err := decode(src, encoded)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return entropy.Of(d.Salt), nil
+}
+
+func safeSummary(src hasherSource, encoded string) (*DecodedHash, error) {
+	d, err := decode(src, encoded)
+
+	if err != nil {
+		return nil, err
+	}
+
+	d.Salt = maskHash(d.Salt, 6)
+	d.Hash = maskHash(d.Hash, 6)
+
+	return d, nil
+}
+
+// maskHash returns the first show characters of s
+// and replaces the others with asterisks.
+func maskHash(s string, show int) string {
+	if len(s) <= show {
+		return s
+	}
+
+	return s[:show] + strings.Repeat("*", len(s)-show)
 }
 
 // CheckPassword validates if the raw password matches the encoded digest.
 //
 // This is a shortcut that discovers the hasher used in the encoded digest
 // to perform the correct validation.
-func CheckPassword(password, encoded string) (bool, error) {
+func CheckPassword(password, encoded string, opts ...Option) (bool, error) {
+	return checkPassword(context.Background(), registry{}, password, encoded, nil, "default", newOptions(opts))
+}
+
+// CheckPasswordContext validates if the raw password matches the encoded digest.
+//
+// It behaves like CheckPassword, except that ctx.Err() is returned
+// as soon as ctx is done.
+func CheckPasswordContext(ctx context.Context, password, encoded string, opts ...Option) (bool, error) {
+	return checkPassword(ctx, registry{}, password, encoded, nil, "default", newOptions(opts))
+}
+
+// CheckPasswordOrDummy validates if the raw password matches the encoded digest.
+//
+// If encoded is unusable, for example empty because the user does not exist,
+// a random password is encoded once with the default hasher to spend the same
+// work as a real verification, and false is returned.
+func CheckPasswordOrDummy(password, encoded string, opts ...Option) (bool, error) {
+	return CheckPassword(password, encoded, append([]Option{WithDummyCheck()}, opts...)...)
+}
+
+// CheckPasswordWithSetter validates if the raw password matches the encoded digest
+// and calls setter with a new encoded password if the stored one must be updated.
+//
+// Like Django, setter is only called if the password is correct and the encoded
+// digest does not use the preferred hasher or its current settings.
+// If preferred is "default", the default hasher is used.
+// An error returned by setter is returned along with the validation result.
+func CheckPasswordWithSetter(password, encoded string, setter func(encoded string) error, preferred string, opts ...Option) (bool, error) {
+	return checkPassword(context.Background(), registry{}, password, encoded, setter, preferred, newOptions(opts))
+}
+
+// hasherSource resolves hashers for the package level functions and Context.
+type hasherSource interface {
+	LookupHasher(algorithm string) (PasswordHasher, error)
+	IdentifyHasher(encoded string) string
+}
+
+// registry is the hasherSource backed by the registered hashers.
+type registry struct{}
+
+func (registry) LookupHasher(algorithm string) (PasswordHasher, error) {
+	return LookupHasher(algorithm)
+}
+
+func (registry) IdentifyHasher(encoded string) string {
+	return IdentifyHasher(encoded)
+}
+
+func checkPassword(ctx context.Context, src hasherSource, password, encoded string, setter func(string) error, preferred string, o *options) (bool, error) {
+	if o.passwordTooLong(password) {
+		return false, ErrPasswordTooLong
+	}
+
 	if !IsPasswordUsable(encoded) {
+		if o.dummyCheck {
+			return false, dummyCheck(ctx, src, preferred, o)
+		}
+
 		return false, nil
 	}
 
-	hasher := IdentifyHasher(encoded)
+	algorithm := src.IdentifyHasher(encoded)
+	h, err := src.LookupHasher(algorithm)
 
-	switch hasher {
-	case Argon2Hasher:
-		return argon2.NewArgon2Hasher().Verify(password, encoded)
-	case BCryptHasher:
-		return bcrypt.NewBCryptHasher().Verify(password, encoded)
-	case BCryptSHA256Hasher:
-		return bcrypt.NewBCryptSHA256Hasher().Verify(password, encoded)
-	case PBKDF2SHA1Hasher:
-		return pbkdf2.NewPBKDF2SHA1Hasher().Verify(password, encoded)
-	case PBKDF2SHA256Hasher:
-		return pbkdf2.NewPBKDF2SHA256Hasher().Verify(password, encoded)
-	case MD5Hasher:
-		return md5.NewMD5PasswordHasher().Verify(password, encoded)
-	case SHA1Hasher:
-		return sha1.NewSHA1PasswordHasher().Verify(password, encoded)
-	case UnsaltedMD5Hasher:
-		return md5.NewUnsaltedMD5PasswordHasher().Verify(password, encoded)
-	case UnsaltedSHA1Hasher:
-		return sha1.NewUnsaltedSHA1PasswordHasher().Verify(password, encoded)
+	if err != nil {
+		return false, err
+	}
+
+	var memory int
+
+	if o.limits != nil || (o.limiter != nil && o.limiter.maxMemory > 0) {
+		d, err := h.Decode(encoded)
+
+		if err != nil {
+			return false, err
+		}
+
+		if o.limits != nil {
+			if err := o.limits.check(d); err != nil {
+				return false, err
+			}
+		}
+
+		memory = verifyMemory(d)
+	}
+
+	var valid bool
+
+	err = o.run(ctx, memory, func(ctx context.Context) (err error) {
+		valid, err = verify(ctx, h, password, encoded)
+		return err
+	})
+
+	if err != nil {
+		return false, err
+	}
+
+	if setter == nil && !o.hardenRuntime {
+		return valid, nil
 	}
 
-	if IsValidHasher(hasher) {
-		return false, ErrHasherNotImplemented
+	p, err := src.LookupHasher(preferred)
+
+	if err != nil {
+		return false, err
 	}
 
-	return false, ErrInvalidHasher
+	mustUpdate := needsRehash(encoded, algorithm, p)
+
+	if valid && mustUpdate && setter != nil {
+		newencoded, err := makePassword(ctx, src, password, "", preferred, o)
+
+		if err != nil {
+			return true, err
+		}
+
+		return true, setter(newencoded)
+	}
+
+	// Encoding the new password already takes as long as a verification
+	// with the preferred settings, otherwise pad the missing work.
+	if mustUpdate && o.hardenRuntime && algorithm == p.Algorithm() {
+		if r, ok := h.(RuntimeHardener); ok {
+			err := o.run(ctx, encodeMemory(p), func(context.Context) error {
+				return r.HardenRuntime(password, encoded)
+			})
+
+			if err != nil {
+				return false, err
+			}
+		}
+	}
+
+	return valid, nil
+}
+
+// dummyCheck encodes a random password with the preferred hasher
+// and discards the result. The password of the caller is not used,
+// because an empty password is not encoded by makePassword.
+func dummyCheck(ctx context.Context, src hasherSource, preferred string, o *options) error {
+	password, err := orDefault(o.saltGenerator).RandomString(UnusablePasswordSuffixLength)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = makePassword(ctx, src, password, "", preferred, o)
+	return err
 }
 
 // MakePassword turns a plain-text password into a hash.
@@ -170,45 +381,66 @@ func CheckPassword(password, encoded string) (bool, error) {
 // If password is empty then return a concatenation
 // of UnusablePasswordPrefix and a random string.
 // If salt is empty then a randon string is generated.
-// BCrypt algorithm ignores salt parameter.
 // If hasher is "default", encode using default hasher.
-func MakePassword(password, salt, hasher string) (string, error) {
+// Passwords longer than DefaultMaxPasswordLength are rejected
+// with ErrPasswordTooLong, see WithMaxPasswordLength.
+// Options such as WithIterations, WithCost and WithArgon2Params
+// override the parameters of the hasher for this call.
+func MakePassword(password, salt, hasher string, opts ...Option) (string, error) {
+	return makePassword(context.Background(), registry{}, password, salt, hasher, newOptions(opts))
+}
+
+// MakePasswordContext turns a plain-text password into a hash.
+//
+// It behaves like MakePassword, except that ctx.Err() is returned
+// as soon as ctx is done.
+func MakePasswordContext(ctx context.Context, password, salt, hasher string, opts ...Option) (string, error) {
+	return makePassword(ctx, registry{}, password, salt, hasher, newOptions(opts))
+}
+
+func makePassword(ctx context.Context, src hasherSource, password, salt, hasher string, o *options) (string, error) {
 	if password == "" {
-		return UnusablePasswordPrefix + GetRandomString(UnusablePasswordSuffixLength), nil
+		suffix, err := orDefault(o.saltGenerator).RandomString(UnusablePasswordSuffixLength)
+
+		if err != nil {
+			return "", err
+		}
+
+		return UnusablePasswordPrefix + suffix, nil
+	}
+
+	if o.passwordTooLong(password) {
+		return "", ErrPasswordTooLong
+	}
+
+	h, err := src.LookupHasher(hasher)
+
+	if err != nil {
+		return "", err
+	}
+
+	if p, ok := h.(paramsHasher); ok {
+		h = p.withParams(o)
 	}
 
 	if salt == "" {
-		salt = GetRandomString(DefaultSaltSize)
+		salt, err = newSalt(h, o)
+
+		if err != nil {
+			return "", err
+		}
 	}
 
-	if hasher == "default" {
-		hasher = DefaultHasher
+	var encoded string
+
+	err = o.run(ctx, encodeMemory(h), func(ctx context.Context) (err error) {
+		encoded, err = encode(ctx, h, password, salt)
+		return err
+	})
+
+	if err != nil {
+		return "", err
 	}
 
-	switch hasher {
-	case Argon2Hasher:
-		return argon2.NewArgon2Hasher().Encode(password, salt)
-	case BCryptHasher:
-		return bcrypt.NewBCryptHasher().Encode(password, salt)
-	case BCryptSHA256Hasher:
-		return bcrypt.NewBCryptSHA256Hasher().Encode(password, salt)
-	case PBKDF2SHA1Hasher:
-		return pbkdf2.NewPBKDF2SHA1Hasher().Encode(password, salt, 0)
-	case PBKDF2SHA256Hasher:
-		return pbkdf2.NewPBKDF2SHA256Hasher().Encode(password, salt, 0)
-	case MD5Hasher:
-		return md5.NewMD5PasswordHasher().Encode(password, salt)
-	case SHA1Hasher:
-		return sha1.NewSHA1PasswordHasher().Encode(password, salt)
-	case UnsaltedMD5Hasher:
-		return md5.NewUnsaltedMD5PasswordHasher().Encode(password)
-	case UnsaltedSHA1Hasher:
-		return sha1.NewUnsaltedSHA1PasswordHasher().Encode(password, salt)
-	}
-
-	if IsValidHasher(hasher) {
-		return "", ErrHasherNotImplemented
-	}
-
-	return "", ErrInvalidHasher
+	return encoded, nil
 }
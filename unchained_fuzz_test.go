@@ -0,0 +1,25 @@
+//go:build go1.18
+// +build go1.18
+
+package unchained
+
+import "testing"
+
+func FuzzCheckPassword(f *testing.F) {
+	f.Add("pbkdf2_sha256$1000$WZrFZhpl3wOU$yPimyWN658IuAu0XErvg1Nowfd55k60hu4o+eDUlBDM=")
+	f.Add("argon2$argon2id$v=19$m=8,t=1,p=1$NnFZNGxmQTE1bmFV$/noD0KSu5OEnoJHi1E4KFA")
+	f.Add("bcrypt$$2b$04$qcNExitVe89wMG.nmRD4Qupn2hFm0pxvnu6VC.w6LShOx30l.F9/.")
+	f.Add("21232f297a57a5a743894a0e4a801fc3")
+	f.Add("sha1$$d033e22ae348aeb5660fc2140aec35850c4da997")
+	f.Add("!unusable")
+
+	limits := Limits{MaxMemory: 64, MaxTime: 2, MaxThreads: 2, MaxIterations: 1000, MaxCost: 4}
+
+	f.Fuzz(func(t *testing.T, encoded string) {
+		IdentifyHasher(encoded)
+		Decode(encoded)
+		SafeSummary(encoded)
+		NeedsRehash(encoded)
+		CheckPassword("admin", encoded, WithLimits(limits))
+	})
+}
@@ -1,9 +1,19 @@
 package unchained
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"math"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/alexandrevicenzi/unchained/bcrypt"
+	"github.com/alexandrevicenzi/unchained/crypt"
+	"github.com/alexandrevicenzi/unchained/md5"
+	"github.com/alexandrevicenzi/unchained/pbkdf2"
 )
 
 func TestMakePasswordDefault(t *testing.T) {
@@ -70,6 +80,32 @@ func TestMakePasswordBCryptSHA256Hasher(t *testing.T) {
 	}
 }
 
+func TestMakePasswordBCryptHasherWithSalt(t *testing.T) {
+	encoded, err := MakePassword("admin", "WZK9cb9qKN.Q5LCYPq/gj.", BCryptSHA256Hasher)
+
+	if err != nil {
+		t.Fatalf("Make password error: %s", err)
+	}
+
+	expected := "bcrypt_sha256$$2b$12$WZK9cb9qKN.Q5LCYPq/gj.6gvry1b37HUsJER6KhQBnIWmPyyaaqi"
+
+	if encoded != expected {
+		t.Fatalf("Encoded password %s does not match %s.", encoded, expected)
+	}
+}
+
+func TestMakePasswordCryptHasher(t *testing.T) {
+	encoded, err := MakePassword("admin", "", CryptHasher)
+
+	if err != nil {
+		t.Fatalf("Make password error: %s", err)
+	}
+
+	if !strings.HasPrefix(encoded, fmt.Sprintf("%s$$", CryptHasher)) || len(encoded) != 20 {
+		t.Fatalf("Encoded password doesn't match algorithm (%s): %s", CryptHasher, encoded)
+	}
+}
+
 func TestMakePasswordMD5Hasher(t *testing.T) {
 	encoded, err := MakePassword("admin", "", MD5Hasher)
 
@@ -106,6 +142,18 @@ func TestMakePasswordPBKDF2SHA256Hasher(t *testing.T) {
 	}
 }
 
+func TestMakePasswordScryptHasher(t *testing.T) {
+	encoded, err := MakePassword("admin", "", ScryptHasher)
+
+	if err != nil {
+		t.Fatalf("Make password error: %s", err)
+	}
+
+	if !strings.HasPrefix(encoded, fmt.Sprintf("%s$", ScryptHasher)) {
+		t.Fatalf("Encoded password doesn't match algorithm (%s): %s", ScryptHasher, encoded)
+	}
+}
+
 func TestMakePasswordSHA1Hasher(t *testing.T) {
 	encoded, err := MakePassword("admin", "", SHA1Hasher)
 
@@ -206,6 +254,18 @@ func TestCheckPasswordBCryptoSHA256(t *testing.T) {
 	}
 }
 
+func TestCheckPasswordCryptHasher(t *testing.T) {
+	valid, err := CheckPassword("admin", "crypt$$abOV.DfJmdnYw")
+
+	if err != nil {
+		t.Fatalf("CheckPassword error: %s", err)
+	}
+
+	if !valid {
+		t.Fatal("Password should be valid.")
+	}
+}
+
 func TestCheckPasswordMD5Hasher(t *testing.T) {
 	valid, err := CheckPassword("admin", "md5$8CjhcHYaEGZQ$c7f218365947cecaac46415390d5cb6a")
 
@@ -218,6 +278,18 @@ func TestCheckPasswordMD5Hasher(t *testing.T) {
 	}
 }
 
+func TestCheckPasswordScryptHasher(t *testing.T) {
+	valid, err := CheckPassword("admin", "scrypt$16384$WZrFZhpl3wOU$8$1$MBWs+8N/mw8Zitnc1lotoVyDezPrF/fZISvRUVuChA7c0iwIhQLNvJO1yHb7nMZuzJxdpl3Y3NEWBsfwLrphTQ==")
+
+	if err != nil {
+		t.Fatalf("CheckPassword error: %s", err)
+	}
+
+	if !valid {
+		t.Fatal("Password should be valid.")
+	}
+}
+
 func TestCheckPasswordSHA1Hasher(t *testing.T) {
 	valid, err := CheckPassword("admin", "sha1$7E3eUiuxfTHG$154faafaf5455924ad853c5f1630eaf062c135a7")
 
@@ -285,3 +357,871 @@ func TestIsPasswordUsableWithEmptyPassword(t *testing.T) {
 		t.Fatal("Password should be unusable.")
 	}
 }
+
+type reverseHasher struct{}
+
+func (h *reverseHasher) Algorithm() string {
+	return "reverse"
+}
+
+func (h *reverseHasher) Encode(password, salt string) (string, error) {
+	b := []byte(password)
+
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+
+	return fmt.Sprintf("reverse$%s$%s", salt, b), nil
+}
+
+func (h *reverseHasher) Verify(password, encoded string) (bool, error) {
+	d, err := h.Decode(encoded)
+
+	if err != nil {
+		return false, err
+	}
+
+	newencoded, _ := h.Encode(password, d.Salt)
+	return newencoded == encoded, nil
+}
+
+func (h *reverseHasher) Decode(encoded string) (*DecodedHash, error) {
+	s := strings.Split(encoded, "$")
+
+	if len(s) != 3 {
+		return nil, ErrInvalidHasher
+	}
+
+	return &DecodedHash{Algorithm: s[0], Salt: s[1], Hash: s[2]}, nil
+}
+
+func (h *reverseHasher) MustUpdate(encoded string) bool {
+	return false
+}
+
+// saveHashers returns a function that restores the registered hashers,
+// so tests that register hashers do not depend on the order they run.
+func saveHashers() func() {
+	hashersMu.RLock()
+	saved := make(map[string]PasswordHasher, len(hashers))
+
+	for algorithm, h := range hashers {
+		saved[algorithm] = h
+	}
+
+	order := append([]string(nil), algorithms...)
+	hashersMu.RUnlock()
+
+	return func() {
+		hashersMu.Lock()
+		hashers = saved
+		algorithms = order
+		hashersMu.Unlock()
+	}
+}
+
+func TestRegisterHasher(t *testing.T) {
+	defer saveHashers()()
+
+	if IsHasherImplemented("reverse") {
+		t.Fatal("Hasher should not be implemented.")
+	}
+
+	RegisterHasher(&reverseHasher{})
+
+	if !IsHasherImplemented("reverse") {
+		t.Fatal("Hasher should be implemented.")
+	}
+
+	encoded, err := MakePassword("admin", "salt", "reverse")
+
+	if err != nil {
+		t.Fatalf("MakePassword error: %s", err)
+	}
+
+	expected := "reverse$salt$nimda"
+
+	if encoded != expected {
+		t.Fatalf("Encoded hash %s does not match %s.", encoded, expected)
+	}
+
+	if hasher := IdentifyHasher(encoded); hasher != "reverse" {
+		t.Fatalf("Hasher %s is not reverse.", hasher)
+	}
+
+	valid, err := CheckPassword("admin", encoded)
+
+	if err != nil {
+		t.Fatalf("CheckPassword error: %s", err)
+	}
+
+	if !valid {
+		t.Fatal("Password should be valid.")
+	}
+}
+
+func TestLookupHasherInvalid(t *testing.T) {
+	_, err := LookupHasher("invalid")
+
+	if err != ErrInvalidHasher {
+		t.Fatalf("Error %v is not %s.", err, ErrInvalidHasher)
+	}
+}
+
+func TestIdentifyHasherUnsalted(t *testing.T) {
+	hasher := IdentifyHasher("21232f297a57a5a743894a0e4a801fc3")
+
+	if hasher != UnsaltedMD5Hasher {
+		t.Fatalf("Hasher %s is not %s.", hasher, UnsaltedMD5Hasher)
+	}
+
+	hasher = IdentifyHasher("sha1$$d033e22ae348aeb5660fc2140aec35850c4da997")
+
+	if hasher != UnsaltedSHA1Hasher {
+		t.Fatalf("Hasher %s is not %s.", hasher, UnsaltedSHA1Hasher)
+	}
+}
+
+func TestNeedsRehashDefaultHasher(t *testing.T) {
+	encoded, err := MakePassword("admin", "", "default")
+
+	if err != nil {
+		t.Fatalf("MakePassword error: %s", err)
+	}
+
+	if NeedsRehash(encoded) {
+		t.Fatal("Password should not need rehash.")
+	}
+}
+
+func TestNeedsRehashIterations(t *testing.T) {
+	if !NeedsRehash("pbkdf2_sha256$120000$WZrFZhpl3wOU$yPimyWN658IuAu0XErvg1Nowfd55k60hu4o+eDUlBDM=") {
+		t.Fatal("Password should need rehash.")
+	}
+}
+
+func TestNeedsRehashShortSalt(t *testing.T) {
+	if !NeedsRehash("pbkdf2_sha256$216000$salt$t5h1W8fP1JV+ItSr0vXAr2UwKp/+VOLOhWg1pX+nvHc=") {
+		t.Fatal("Password should need rehash.")
+	}
+}
+
+func TestNeedsRehashOtherHasher(t *testing.T) {
+	if !NeedsRehash("21232f297a57a5a743894a0e4a801fc3") {
+		t.Fatal("Password should need rehash.")
+	}
+}
+
+func TestNeedsRehashUnusablePassword(t *testing.T) {
+	if NeedsRehash("!password") {
+		t.Fatal("Password should not need rehash.")
+	}
+}
+
+func TestCheckPasswordWithSetterUpgrade(t *testing.T) {
+	var updated string
+
+	setter := func(encoded string) error {
+		updated = encoded
+		return nil
+	}
+
+	for _, encoded := range []string{
+		"21232f297a57a5a743894a0e4a801fc3",
+		"sha1$$d033e22ae348aeb5660fc2140aec35850c4da997",
+		"pbkdf2_sha256$120000$WZrFZhpl3wOU$yPimyWN658IuAu0XErvg1Nowfd55k60hu4o+eDUlBDM=",
+	} {
+		updated = ""
+		valid, err := CheckPasswordWithSetter("admin", encoded, setter, "default")
+
+		if err != nil {
+			t.Fatalf("CheckPasswordWithSetter error: %s", err)
+		}
+
+		if !valid {
+			t.Fatalf("Password should be valid: %s", encoded)
+		}
+
+		if IdentifyHasher(updated) != DefaultHasher {
+			t.Fatalf("Password %s should be updated to %s: %s", encoded, DefaultHasher, updated)
+		}
+
+		if NeedsRehash(updated) {
+			t.Fatalf("Updated password should not need rehash: %s", updated)
+		}
+	}
+}
+
+func TestCheckPasswordWithSetterPreferred(t *testing.T) {
+	var updated string
+
+	setter := func(encoded string) error {
+		updated = encoded
+		return nil
+	}
+
+	valid, err := CheckPasswordWithSetter("admin", "md5$8CjhcHYaEGZQ$c7f218365947cecaac46415390d5cb6a", setter, SHA1Hasher)
+
+	if err != nil {
+		t.Fatalf("CheckPasswordWithSetter error: %s", err)
+	}
+
+	if !valid {
+		t.Fatal("Password should be valid.")
+	}
+
+	if !strings.HasPrefix(updated, "sha1$") {
+		t.Fatalf("Password should be updated to %s: %s", SHA1Hasher, updated)
+	}
+}
+
+func TestCheckPasswordWithSetterUpToDate(t *testing.T) {
+	encoded, err := MakePassword("admin", "", "default")
+
+	if err != nil {
+		t.Fatalf("MakePassword error: %s", err)
+	}
+
+	called := false
+
+	valid, err := CheckPasswordWithSetter("admin", encoded, func(string) error {
+		called = true
+		return nil
+	}, "default")
+
+	if err != nil {
+		t.Fatalf("CheckPasswordWithSetter error: %s", err)
+	}
+
+	if !valid {
+		t.Fatal("Password should be valid.")
+	}
+
+	if called {
+		t.Fatal("Setter should not be called.")
+	}
+}
+
+func TestCheckPasswordWithSetterInvalidPassword(t *testing.T) {
+	called := false
+
+	valid, err := CheckPasswordWithSetter("wrongpassword", "21232f297a57a5a743894a0e4a801fc3", func(string) error {
+		called = true
+		return nil
+	}, "default")
+
+	if err != nil {
+		t.Fatalf("CheckPasswordWithSetter error: %s", err)
+	}
+
+	if valid {
+		t.Fatal("Password should not be valid.")
+	}
+
+	if called {
+		t.Fatal("Setter should not be called.")
+	}
+}
+
+func TestCheckPasswordWithSetterError(t *testing.T) {
+	errSetter := fmt.Errorf("setter failed")
+
+	valid, err := CheckPasswordWithSetter("admin", "21232f297a57a5a743894a0e4a801fc3", func(string) error {
+		return errSetter
+	}, "default")
+
+	if err != errSetter {
+		t.Fatalf("Error %v is not %s.", err, errSetter)
+	}
+
+	if !valid {
+		t.Fatal("Password should be valid.")
+	}
+}
+
+type hardenHasher struct {
+	reverseHasher
+	hardened int
+}
+
+func (h *hardenHasher) MustUpdate(encoded string) bool {
+	return true
+}
+
+func (h *hardenHasher) HardenRuntime(password, encoded string) error {
+	h.hardened++
+	return nil
+}
+
+func TestCheckPasswordHardenRuntime(t *testing.T) {
+	h := &hardenHasher{}
+	c, err := NewContext(h)
+
+	if err != nil {
+		t.Fatalf("NewContext error: %s", err)
+	}
+
+	for _, password := range []string{"admin", "wrongpassword"} {
+		if _, err := c.CheckPassword(password, "reverse$salt$nimda"); err != nil {
+			t.Fatalf("CheckPassword error: %s", err)
+		}
+	}
+
+	if h.hardened != 0 {
+		t.Fatal("Runtime should not be hardened without option.")
+	}
+
+	for _, password := range []string{"admin", "wrongpassword"} {
+		if _, err := c.CheckPassword(password, "reverse$salt$nimda", WithHardenRuntime()); err != nil {
+			t.Fatalf("CheckPassword error: %s", err)
+		}
+	}
+
+	if h.hardened != 2 {
+		t.Fatalf("Runtime should be hardened twice, got %d.", h.hardened)
+	}
+}
+
+func TestCheckPasswordHardenRuntimePBKDF2(t *testing.T) {
+	valid, err := CheckPassword("wrongpassword", "pbkdf2_sha256$120000$WZrFZhpl3wOU$yPimyWN658IuAu0XErvg1Nowfd55k60hu4o+eDUlBDM=", WithHardenRuntime())
+
+	if err != nil {
+		t.Fatalf("CheckPassword error: %s", err)
+	}
+
+	if valid {
+		t.Fatal("Password should not be valid.")
+	}
+}
+
+type countingHasher struct {
+	reverseHasher
+	encoded int
+}
+
+func (h *countingHasher) Encode(password, salt string) (string, error) {
+	h.encoded++
+	return h.reverseHasher.Encode(password, salt)
+}
+
+func TestCheckPasswordDummyCheck(t *testing.T) {
+	h := &countingHasher{}
+	c, err := NewContext(h)
+
+	if err != nil {
+		t.Fatalf("NewContext error: %s", err)
+	}
+
+	for _, encoded := range []string{"", "!unusable"} {
+		valid, err := c.CheckPassword("admin", encoded)
+
+		if err != nil {
+			t.Fatalf("CheckPassword error: %s", err)
+		}
+
+		if valid {
+			t.Fatal("Password should not be valid.")
+		}
+	}
+
+	if h.encoded != 0 {
+		t.Fatal("Default hasher should not be used without option.")
+	}
+
+	for _, encoded := range []string{"", "!unusable"} {
+		valid, err := c.CheckPassword("admin", encoded, WithDummyCheck())
+
+		if err != nil {
+			t.Fatalf("CheckPassword error: %s", err)
+		}
+
+		if valid {
+			t.Fatal("Password should not be valid.")
+		}
+	}
+
+	if h.encoded != 2 {
+		t.Fatalf("Default hasher should be used twice, got %d.", h.encoded)
+	}
+
+	// Empty passwords must take as long as the others.
+	if valid, err := c.CheckPasswordOrDummy("", ""); valid || err != nil {
+		t.Fatalf("Password should not be valid, error: %v", err)
+	}
+
+	if h.encoded != 3 {
+		t.Fatalf("Default hasher should be used for an empty password, got %d.", h.encoded)
+	}
+}
+
+func TestCheckPasswordOrDummy(t *testing.T) {
+	valid, err := CheckPasswordOrDummy("admin", "")
+
+	if err != nil {
+		t.Fatalf("CheckPasswordOrDummy error: %s", err)
+	}
+
+	if valid {
+		t.Fatal("Password should not be valid.")
+	}
+
+	valid, err = CheckPasswordOrDummy("admin", "21232f297a57a5a743894a0e4a801fc3")
+
+	if err != nil {
+		t.Fatalf("CheckPasswordOrDummy error: %s", err)
+	}
+
+	if !valid {
+		t.Fatal("Password should be valid.")
+	}
+}
+
+func TestDecodeArgon2(t *testing.T) {
+	d, err := Decode("argon2$argon2i$v=19$m=512,t=2,p=2$NnFZNGxmQTE1bmFV$kPPGrqD6dnRllcQeksFN+w")
+
+	if err != nil {
+		t.Fatalf("Decode error: %s", err)
+	}
+
+	if d.Algorithm != Argon2Hasher || d.Variant != "argon2i" || d.Version != "19" || d.Salt != "6qY4lfA15naU" || d.Hash != "kPPGrqD6dnRllcQeksFN+w" {
+		t.Fatalf("Decoded hash %+v does not match.", d)
+	}
+
+	if d.Params["memory_cost"] != 512 || d.Params["time_cost"] != 2 || d.Params["parallelism"] != 2 {
+		t.Fatalf("Decoded params %v do not match.", d.Params)
+	}
+}
+
+func TestDecodeBCrypt(t *testing.T) {
+	d, err := Decode("bcrypt_sha256$$2b$12$WZK9cb9qKN.Q5LCYPq/gj.6gvry1b37HUsJER6KhQBnIWmPyyaaqi")
+
+	if err != nil {
+		t.Fatalf("Decode error: %s", err)
+	}
+
+	if d.Algorithm != BCryptSHA256Hasher || d.Version != "2b" || d.Params["work_factor"] != 12 {
+		t.Fatalf("Decoded hash %+v does not match.", d)
+	}
+}
+
+func TestDecodePBKDF2(t *testing.T) {
+	d, err := Decode("pbkdf2_sha256$120000$WZrFZhpl3wOU$yPimyWN658IuAu0XErvg1Nowfd55k60hu4o+eDUlBDM=")
+
+	if err != nil {
+		t.Fatalf("Decode error: %s", err)
+	}
+
+	if d.Algorithm != PBKDF2SHA256Hasher || d.Salt != "WZrFZhpl3wOU" || d.Params["iterations"] != 120000 {
+		t.Fatalf("Decoded hash %+v does not match.", d)
+	}
+}
+
+func TestDecodeUnsaltedMD5(t *testing.T) {
+	d, err := Decode("21232f297a57a5a743894a0e4a801fc3")
+
+	if err != nil {
+		t.Fatalf("Decode error: %s", err)
+	}
+
+	if d.Algorithm != UnsaltedMD5Hasher || d.Salt != "" || d.Hash != "21232f297a57a5a743894a0e4a801fc3" {
+		t.Fatalf("Decoded hash %+v does not match.", d)
+	}
+}
+
+func TestDecodeInvalidHasher(t *testing.T) {
+	_, err := Decode("invalid$hash")
+
+	if err != ErrInvalidHasher {
+		t.Fatalf("Error %v is not %s.", err, ErrInvalidHasher)
+	}
+}
+
+func TestSafeSummary(t *testing.T) {
+	d, err := SafeSummary("pbkdf2_sha256$120000$WZrFZhpl3wOU$yPimyWN658IuAu0XErvg1Nowfd55k60hu4o+eDUlBDM=")
+
+	if err != nil {
+		t.Fatalf("SafeSummary error: %s", err)
+	}
+
+	if d.Salt != "WZrFZh******" {
+		t.Fatalf("Salt %s is not masked.", d.Salt)
+	}
+
+	if d.Hash != "yPimyW**************************************" {
+		t.Fatalf("Hash %s is not masked.", d.Hash)
+	}
+
+	if d.Params["iterations"] != 120000 {
+		t.Fatalf("Decoded params %v do not match.", d.Params)
+	}
+}
+
+type blockingHasher struct {
+	reverseHasher
+	release chan struct{}
+}
+
+func (h *blockingHasher) Encode(password, salt string) (string, error) {
+	<-h.release
+	return h.reverseHasher.Encode(password, salt)
+}
+
+func (h *blockingHasher) Verify(password, encoded string) (bool, error) {
+	<-h.release
+	return h.reverseHasher.Verify(password, encoded)
+}
+
+func TestCheckPasswordContextDeadline(t *testing.T) {
+	h := &blockingHasher{release: make(chan struct{})}
+	defer close(h.release)
+
+	c, err := NewContext(h)
+
+	if err != nil {
+		t.Fatalf("NewContext error: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := c.CheckPasswordContext(ctx, "admin", "reverse$salt$nimda"); err != context.DeadlineExceeded {
+		t.Fatalf("Error %v is not %s.", err, context.DeadlineExceeded)
+	}
+
+	if _, err := c.MakePasswordContext(ctx, "admin", "salt", "default"); err != context.DeadlineExceeded {
+		t.Fatalf("Error %v is not %s.", err, context.DeadlineExceeded)
+	}
+}
+
+func TestCheckPasswordContext(t *testing.T) {
+	valid, err := CheckPasswordContext(context.Background(), "admin", "pbkdf2_sha256$120000$WZrFZhpl3wOU$yPimyWN658IuAu0XErvg1Nowfd55k60hu4o+eDUlBDM=")
+
+	if err != nil {
+		t.Fatalf("CheckPassword error: %s", err)
+	}
+
+	if !valid {
+		t.Fatal("Password should be valid.")
+	}
+}
+
+func TestMakePasswordContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for _, hasher := range []string{PBKDF2SHA256Hasher, Argon2Hasher} {
+		if _, err := MakePasswordContext(ctx, "admin", "", hasher); err != context.Canceled {
+			t.Fatalf("Error %v is not %s for %s.", err, context.Canceled, hasher)
+		}
+	}
+}
+
+func TestCheckPasswordHashError(t *testing.T) {
+	_, err := CheckPassword("admin", "pbkdf2_sha256$many$WZrFZhpl3wOU$yPimyWN658IuAu0XErvg1Nowfd55k60hu4o+eDUlBDM=")
+
+	if !errors.Is(err, ErrMalformedHash) {
+		t.Fatalf("Error %v is not %s.", err, ErrMalformedHash)
+	}
+
+	if !errors.Is(err, pbkdf2.ErrHashComponentUnreadable) {
+		t.Fatalf("Error %v is not %s.", err, pbkdf2.ErrHashComponentUnreadable)
+	}
+
+	var hashErr *HashError
+
+	if !errors.As(err, &hashErr) {
+		t.Fatalf("Error %v is not a HashError.", err)
+	}
+
+	if hashErr.Algorithm != PBKDF2SHA256Hasher || hashErr.Component != "iterations" {
+		t.Fatalf("HashError %+v does not match.", hashErr)
+	}
+}
+
+func TestCheckPasswordErrorCategories(t *testing.T) {
+	tests := []struct {
+		encoded  string
+		category error
+	}{
+		{"pbkdf2_sha256$120000$WZrFZhpl3wOU", ErrMalformedHash},
+		{"argon2$argon2id$v=16$m=512,t=2,p=2$NnFZNGxmQTE1bmFV$/noD0KSu5OEnoJHi1E4KFA", ErrUnsupportedVersion},
+		{"argon2$argon2x$v=19$m=512,t=2,p=2$NnFZNGxmQTE1bmFV$/noD0KSu5OEnoJHi1E4KFA", ErrUnsupportedAlgorithm},
+		{"bcrypt$$2b$32$qcNExitVe89wMG.nmRD4Qupn2hFm0pxvnu6VC.w6LShOx30l.F9/.", ErrInvalidParameters},
+		{"scrypt$1048576$salt$8$1$Y2JkZWY=", ErrLimitExceeded},
+		{"md5$$21232f297a57a5a743894a0e4a801fc3a", ErrInvalidSalt},
+		{"unknown$hash", ErrUnsupportedAlgorithm},
+	}
+
+	for _, test := range tests {
+		_, err := CheckPassword("admin", test.encoded)
+
+		if !errors.Is(err, test.category) {
+			t.Fatalf("Error %v is not %s for %s.", err, test.category, test.encoded)
+		}
+	}
+}
+
+func TestCheckPasswordWithLimits(t *testing.T) {
+	tests := []struct {
+		encoded   string
+		limits    Limits
+		component string
+	}{
+		{"pbkdf2_sha256$120000$WZrFZhpl3wOU$yPimyWN658IuAu0XErvg1Nowfd55k60hu4o+eDUlBDM=", Limits{MaxIterations: 100000}, "iterations"},
+		{"argon2$argon2id$v=19$m=512,t=2,p=2$NnFZNGxmQTE1bmFV$/noD0KSu5OEnoJHi1E4KFA", Limits{MaxMemory: 256}, "memory_cost"},
+		{"argon2$argon2id$v=19$m=512,t=2,p=2$NnFZNGxmQTE1bmFV$/noD0KSu5OEnoJHi1E4KFA", Limits{MaxTime: 1}, "time_cost"},
+		{"argon2$argon2id$v=19$m=512,t=2,p=2$NnFZNGxmQTE1bmFV$/noD0KSu5OEnoJHi1E4KFA", Limits{MaxThreads: 1}, "parallelism"},
+		{"argon2$argon2id$v=19$m=512,t=2,p=2$NnFZNGxmQTE1bmFV$/noD0KSu5OEnoJHi1E4KFA", Limits{MaxHashLength: 16}, "hash"},
+		{"bcrypt$$2b$12$qcNExitVe89wMG.nmRD4Qupn2hFm0pxvnu6VC.w6LShOx30l.F9/.", Limits{MaxCost: 10}, "work_factor"},
+		{"scrypt$16384$salt$8$1$Y2JkZWY=", Limits{MaxMemory: 8 * 1024}, "memory_cost"},
+	}
+
+	for _, test := range tests {
+		_, err := CheckPassword("admin", test.encoded, WithLimits(test.limits))
+
+		if !errors.Is(err, ErrLimitExceeded) {
+			t.Fatalf("Error %v is not %s for %s.", err, ErrLimitExceeded, test.encoded)
+		}
+
+		var hashErr *HashError
+
+		if !errors.As(err, &hashErr) || hashErr.Component != test.component {
+			t.Fatalf("Error %v is not a HashError for %s.", err, test.component)
+		}
+	}
+
+	valid, err := CheckPassword("admin", "pbkdf2_sha256$120000$WZrFZhpl3wOU$yPimyWN658IuAu0XErvg1Nowfd55k60hu4o+eDUlBDM=", WithLimits(Limits{MaxIterations: 120000}))
+
+	if err != nil {
+		t.Fatalf("CheckPassword error: %s", err)
+	}
+
+	if !valid {
+		t.Fatal("Password should be valid.")
+	}
+}
+
+func TestMaxPasswordLength(t *testing.T) {
+	password := strings.Repeat("a", DefaultMaxPasswordLength+1)
+
+	if _, err := MakePassword(password, "", "default"); err != ErrPasswordTooLong {
+		t.Fatalf("Error %v is not %s.", err, ErrPasswordTooLong)
+	}
+
+	if _, err := CheckPassword(password, "pbkdf2_sha256$120000$WZrFZhpl3wOU$yPimyWN658IuAu0XErvg1Nowfd55k60hu4o+eDUlBDM="); err != ErrPasswordTooLong {
+		t.Fatalf("Error %v is not %s.", err, ErrPasswordTooLong)
+	}
+
+	if _, err := MakePassword("admin", "", MD5Hasher, WithMaxPasswordLength(4)); err != ErrPasswordTooLong {
+		t.Fatalf("Error %v is not %s.", err, ErrPasswordTooLong)
+	}
+
+	if _, err := MakePassword(password, "", MD5Hasher, WithMaxPasswordLength(0)); err != nil {
+		t.Fatalf("Make password error: %s", err)
+	}
+}
+
+func TestMakePasswordWithSaltGenerator(t *testing.T) {
+	g := NewRandomStringGenerator(bytes.NewReader(make([]byte, 1024)), "")
+	b := bcrypt.NewBCryptHasher()
+	b.Cost = 4
+
+	for _, tc := range []struct {
+		hasher PasswordHasher
+		salt   string
+	}{
+		{MD5(md5.NewMD5PasswordHasher()), strings.Repeat("a", 12)},
+		{PBKDF2(pbkdf2.NewPBKDF2SHA256Hasher()), strings.Repeat("a", 12)},
+		{Crypt(crypt.NewCryptPasswordHasher()), "aa"},
+		// The last character of a bcrypt salt only holds 2 bits.
+		{BCrypt(b), strings.Repeat("a", 21) + "O"},
+	} {
+		c, err := NewContext(tc.hasher)
+
+		if err != nil {
+			t.Fatalf("NewContext error: %s", err)
+		}
+
+		encoded, err := c.MakePassword("admin", "", "default", WithSaltGenerator(g))
+
+		if err != nil {
+			t.Fatalf("MakePassword error: %s", err)
+		}
+
+		d, err := tc.hasher.Decode(encoded)
+
+		if err != nil {
+			t.Fatalf("Decode error: %s", err)
+		}
+
+		if d.Salt != tc.salt {
+			t.Fatalf("Salt %s is not %s.", d.Salt, tc.salt)
+		}
+	}
+}
+
+func TestMakePasswordWithSaltGeneratorError(t *testing.T) {
+	errEntropy := errors.New("no entropy")
+	g := NewRandomStringGenerator(errReader{errEntropy}, "")
+
+	if _, err := MakePassword("admin", "", "default", WithSaltGenerator(g)); err != errEntropy {
+		t.Fatalf("Error %v is not %s.", err, errEntropy)
+	}
+
+	if _, err := MakePassword("", "", "default", WithSaltGenerator(g)); err != errEntropy {
+		t.Fatalf("Error %v is not %s.", err, errEntropy)
+	}
+}
+
+func TestMakePasswordWithSaltEntropy(t *testing.T) {
+	for bits, length := range map[int]int{
+		0:   12,
+		128: 22,
+		256: 43,
+	} {
+		encoded, err := MakePassword("admin", "", MD5Hasher, WithSaltEntropy(bits))
+
+		if err != nil {
+			t.Fatalf("MakePassword error: %s", err)
+		}
+
+		d, err := Decode(encoded)
+
+		if err != nil {
+			t.Fatalf("Decode error: %s", err)
+		}
+
+		if len(d.Salt) != length {
+			t.Fatalf("Salt %s does not have %d characters.", d.Salt, length)
+		}
+	}
+}
+
+func TestSaltEntropy(t *testing.T) {
+	for encoded, expected := range map[string]float64{
+		"pbkdf2_sha256$120000$WZrFZhpl3wOU$yPimyWN658IuAu0XErvg1Nowfd55k60hu4o+eDUlBDM=": 71.45,
+		"md5$aaaaaaaaaaaaaaaaaaaaaa$0f6c2f4e6e5a2a4c8bbd9d3e3f2a1b0c":                    130.99,
+		"21232f297a57a5a743894a0e4a801fc3":                                               0,
+	} {
+		bits, err := SaltEntropy(encoded)
+
+		if err != nil {
+			t.Fatalf("SaltEntropy error: %s", err)
+		}
+
+		if math.Abs(bits-expected) > 0.01 {
+			t.Fatalf("Salt entropy %f is not %f.", bits, expected)
+		}
+	}
+
+	if _, err := SaltEntropy("invalid$hash"); err != ErrInvalidHasher {
+		t.Fatalf("Error %v is not %s.", err, ErrInvalidHasher)
+	}
+}
+
+func TestNeedsRehashSaltEntropy(t *testing.T) {
+	h := pbkdf2.NewPBKDF2SHA256Hasher()
+	h.Iterations = 120000
+	c, err := NewContext(PBKDF2(h))
+
+	if err != nil {
+		t.Fatalf("NewContext error: %s", err)
+	}
+
+	if c.NeedsRehash("pbkdf2_sha256$120000$WZrFZhpl3wOU$yPimyWN658IuAu0XErvg1Nowfd55k60hu4o+eDUlBDM=") {
+		t.Fatal("Password should not need rehash.")
+	}
+
+	h.SaltEntropy = 128
+
+	if !c.NeedsRehash("pbkdf2_sha256$120000$WZrFZhpl3wOU$yPimyWN658IuAu0XErvg1Nowfd55k60hu4o+eDUlBDM=") {
+		t.Fatal("Password should need rehash.")
+	}
+}
+
+func TestMakePasswordWithParams(t *testing.T) {
+	for _, tc := range []struct {
+		hasher string
+		opts   []Option
+		params map[string]int
+	}{
+		{PBKDF2SHA256Hasher, []Option{WithIterations(1000)}, map[string]int{"iterations": 1000}},
+		{PBKDF2SHA256Hasher, []Option{WithCost(5)}, map[string]int{"iterations": 216000}},
+		{BCryptHasher, []Option{WithCost(4)}, map[string]int{"work_factor": 4}},
+		{Argon2Hasher, []Option{WithArgon2Params(1, 64, 1)}, map[string]int{"time_cost": 1, "memory_cost": 64, "parallelism": 1}},
+		{Argon2Hasher, []Option{WithArgon2Params(1, 0, 0)}, map[string]int{"time_cost": 1, "memory_cost": 102400, "parallelism": 8}},
+	} {
+		encoded, err := MakePassword("admin", "", tc.hasher, tc.opts...)
+
+		if err != nil {
+			t.Fatalf("MakePassword error: %s", err)
+		}
+
+		d, err := Decode(encoded)
+
+		if err != nil {
+			t.Fatalf("Decode error: %s", err)
+		}
+
+		for k, v := range tc.params {
+			if d.Params[k] != v {
+				t.Fatalf("%s: %s is %d, expected %d.", tc.hasher, k, d.Params[k], v)
+			}
+		}
+
+		if valid, err := CheckPassword("admin", encoded); !valid || err != nil {
+			t.Fatalf("Password should be valid, error: %v", err)
+		}
+	}
+
+	// The registered hasher is not modified.
+	encoded, err := MakePassword("admin", "", PBKDF2SHA256Hasher)
+
+	if err != nil {
+		t.Fatalf("MakePassword error: %s", err)
+	}
+
+	if !strings.HasPrefix(encoded, "pbkdf2_sha256$216000$") {
+		t.Fatalf("Encoded password %s does not use the hasher iterations.", encoded)
+	}
+}
+
+func TestMakePasswordWithInvalidParams(t *testing.T) {
+	if _, err := MakePassword("admin", "", BCryptHasher, WithCost(3)); !errors.Is(err, ErrInvalidParameters) {
+		t.Fatalf("Error %v is not %s.", err, ErrInvalidParameters)
+	}
+
+	if _, err := MakePassword("admin", "", PBKDF2SHA256Hasher, WithIterations(-1)); !errors.Is(err, ErrInvalidParameters) {
+		t.Fatalf("Error %v is not %s.", err, ErrInvalidParameters)
+	}
+}
+
+func TestMakePasswordWithSaltLengthAndRandom(t *testing.T) {
+	encoded, err := MakePassword("admin", "", MD5Hasher, WithSaltLength(16), WithRandom(bytes.NewReader(make([]byte, 64))))
+
+	if err != nil {
+		t.Fatalf("MakePassword error: %s", err)
+	}
+
+	if expected := "md5$aaaaaaaaaaaaaaaa$"; !strings.HasPrefix(encoded, expected) {
+		t.Fatalf("Encoded password %s does not start with %s.", encoded, expected)
+	}
+}
+
+func TestNeedsRehashDefaults(t *testing.T) {
+	encoded, err := MakePassword("admin", "", "default")
+
+	if err != nil {
+		t.Fatalf("MakePassword error: %s", err)
+	}
+
+	// The default salt entropy matches the default iterations of Django 3.1.
+	for _, encoded := range []string{
+		encoded,
+		"pbkdf2_sha256$216000$1TMOT0Rohg3g$N+wIigWW4zpxnFBwXTWK1Qt8C9aduBIAayDS2ee8KxI=",
+	} {
+		if NeedsRehash(encoded) {
+			t.Fatalf("Password %s should not need rehash.", encoded)
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package unchained
+
+import (
+	"context"
+	"strings"
+
+	"github.com/alexandrevicenzi/unchained/argon2"
+	"github.com/alexandrevicenzi/unchained/internal/hasherr"
+	"github.com/alexandrevicenzi/unchained/pbkdf2"
+)
+
+// ErrWrappedComponentMismatch is returned if an encoded password
+// does not start with the algorithm of the wrapped hasher.
+var ErrWrappedComponentMismatch = hasherr.New(ErrMalformedHash, "unchained: wrapped password components mismatch")
+
+// WrappedHasher encodes the digest of a weak hasher, such as salted SHA1
+// or unsalted MD5, with a strong hasher, like Django's
+// PBKDF2WrappedSHA1PasswordHasher.
+//
+// Existing weak hashes can be converted with Wrap, without the
+// plain-text passwords. The salt of the weak hash is reused,
+// so wrapped passwords usually need a rehash on the next login.
+type WrappedHasher struct {
+	algorithm string
+	outer     PasswordHasher
+	inner     PasswordHasher
+}
+
+// NewWrappedHasher returns a WrappedHasher identified by algorithm,
+// that encodes the digest computed by inner with outer.
+//
+// The digest is the last component of the password encoded by inner,
+// e.g. the hex hash of sha1$<salt>$<hash>, and inner uses the salt of outer.
+func NewWrappedHasher(algorithm string, outer, inner PasswordHasher) *WrappedHasher {
+	return &WrappedHasher{algorithm: algorithm, outer: outer, inner: inner}
+}
+
+// PBKDF2Wrapped returns a WrappedHasher that encodes the digest of inner
+// with PBKDF2, identified by pbkdf2_wrapped_<inner algorithm>,
+// e.g. pbkdf2_wrapped_sha1 as in Django's documentation.
+func PBKDF2Wrapped(h *pbkdf2.PBKDF2Hasher, inner PasswordHasher) *WrappedHasher {
+	return NewWrappedHasher("pbkdf2_wrapped_"+inner.Algorithm(), PBKDF2(h), inner)
+}
+
+// Argon2Wrapped returns a WrappedHasher that encodes the digest of inner
+// with Argon2, identified by argon2_wrapped_<inner algorithm>.
+func Argon2Wrapped(h *argon2.Argon2Hasher, inner PasswordHasher) *WrappedHasher {
+	return NewWrappedHasher("argon2_wrapped_"+inner.Algorithm(), Argon2(h), inner)
+}
+
+// Algorithm returns the identifier of the wrapped hasher.
+func (w *WrappedHasher) Algorithm() string {
+	return w.algorithm
+}
+
+// digest returns the digest of the password computed by the inner hasher.
+func (w *WrappedHasher) digest(password, salt string) (string, error) {
+	encoded, err := w.inner.Encode(password, salt)
+
+	if err != nil {
+		return "", err
+	}
+
+	return encoded[strings.LastIndex(encoded, "$")+1:], nil
+}
+
+// wrap replaces the algorithm of the password encoded by outer.
+func (w *WrappedHasher) wrap(encoded string) string {
+	return w.algorithm + strings.TrimPrefix(encoded, w.outer.Algorithm())
+}
+
+// unwrap returns the password encoded by outer.
+func (w *WrappedHasher) unwrap(encoded string) (string, error) {
+	if !strings.HasPrefix(encoded, w.algorithm+"$") {
+		return "", hasherr.Wrap(w.algorithm, "algorithm", ErrWrappedComponentMismatch)
+	}
+
+	return w.outer.Algorithm() + strings.TrimPrefix(encoded, w.algorithm), nil
+}
+
+// Encode turns a plain-text password into a hash.
+func (w *WrappedHasher) Encode(password, salt string) (string, error) {
+	return w.EncodeContext(context.Background(), password, salt)
+}
+
+// Verify if a plain-text password matches the encoded digest.
+func (w *WrappedHasher) Verify(password, encoded string) (bool, error) {
+	return w.VerifyContext(context.Background(), password, encoded)
+}
+
+// EncodeContext turns a plain-text password into a hash,
+// or returns ctx.Err() if ctx is done first.
+func (w *WrappedHasher) EncodeContext(ctx context.Context, password, salt string) (string, error) {
+	digest, err := w.digest(password, salt)
+
+	if err != nil {
+		return "", err
+	}
+
+	encoded, err := encode(ctx, w.outer, digest, salt)
+
+	if err != nil {
+		return "", err
+	}
+
+	return w.wrap(encoded), nil
+}
+
+// VerifyContext checks if a plain-text password matches the encoded digest,
+// or returns ctx.Err() if ctx is done first.
+func (w *WrappedHasher) VerifyContext(ctx context.Context, password, encoded string) (bool, error) {
+	inner, digest, err := w.unwrapDigest(password, encoded)
+
+	if err != nil {
+		return false, err
+	}
+
+	return verify(ctx, w.outer, digest, inner)
+}
+
+// unwrapDigest returns the password encoded by outer and the digest
+// of the plain-text password computed with its salt.
+func (w *WrappedHasher) unwrapDigest(password, encoded string) (string, string, error) {
+	inner, err := w.unwrap(encoded)
+
+	if err != nil {
+		return "", "", err
+	}
+
+	d, err := w.outer.Decode(inner)
+
+	if err != nil {
+		return "", "", err
+	}
+
+	digest, err := w.digest(password, d.Salt)
+
+	if err != nil {
+		return "", "", err
+	}
+
+	return inner, digest, nil
+}
+
+// Decode splits the encoded password into its components.
+func (w *WrappedHasher) Decode(encoded string) (*DecodedHash, error) {
+	inner, err := w.unwrap(encoded)
+
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := w.outer.Decode(inner)
+
+	if err != nil {
+		return nil, err
+	}
+
+	d.Algorithm = w.algorithm
+
+	return d, nil
+}
+
+// MustUpdate returns true if the outer hasher must update the encoded password.
+func (w *WrappedHasher) MustUpdate(encoded string) bool {
+	inner, err := w.unwrap(encoded)
+
+	if err != nil {
+		return false
+	}
+
+	return w.outer.MustUpdate(inner)
+}
+
+// HardenRuntime pads the verification of encoded up to the outer hasher settings.
+func (w *WrappedHasher) HardenRuntime(password, encoded string) error {
+	r, ok := w.outer.(RuntimeHardener)
+
+	if !ok {
+		return nil
+	}
+
+	inner, digest, err := w.unwrapDigest(password, encoded)
+
+	if err != nil {
+		return err
+	}
+
+	return r.HardenRuntime(digest, inner)
+}
+
+// Wrap converts a password encoded by the inner hasher into
+// a password encoded by w, using the salt of the encoded password.
+// Unsalted passwords get a new salt.
+func (w *WrappedHasher) Wrap(encoded string) (string, error) {
+	d, err := w.inner.Decode(encoded)
+
+	if err != nil {
+		return "", err
+	}
+
+	salt := d.Salt
+
+	if salt == "" {
+		if salt, err = newSalt(w.outer, &options{}); err != nil {
+			return "", err
+		}
+	}
+
+	wrapped, err := w.outer.Encode(d.Hash, salt)
+
+	if err != nil {
+		return "", err
+	}
+
+	return w.wrap(wrapped), nil
+}
+
+// Salt returns a new random salt suitable for the outer hasher.
+func (w *WrappedHasher) Salt() (string, error) {
+	return newSalt(w.outer, &options{})
+}
+
+func (w *WrappedHasher) saltFrom(g SaltGenerator, length int) (string, error) {
+	return newSalt(w.outer, &options{saltGenerator: g, saltLength: length})
+}
+
+func (w *WrappedHasher) withParams(o *options) PasswordHasher {
+	p, ok := w.outer.(paramsHasher)
+
+	if !ok {
+		return w
+	}
+
+	return NewWrappedHasher(w.algorithm, p.withParams(o), w.inner)
+}
+
+func (w *WrappedHasher) memoryCost() int {
+	return encodeMemory(w.outer)
+}
+
+// WrapPassword converts a password encoded by the inner hasher of
+// one of the wrappers into the format of that wrapper, so weak hashes
+// can be upgraded in bulk without the plain-text passwords.
+//
+// ErrInvalidHasher is returned if no wrapper accepts the encoded password.
+func WrapPassword(encoded string, wrappers ...*WrappedHasher) (string, error) {
+	list := make([]PasswordHasher, len(wrappers))
+
+	for i, w := range wrappers {
+		list[i] = w.inner
+	}
+
+	algorithm := identifyHasher(encoded, list)
+
+	for _, w := range wrappers {
+		if w.inner.Algorithm() == algorithm {
+			return w.Wrap(encoded)
+		}
+	}
+
+	return "", ErrInvalidHasher
+}
@@ -0,0 +1,147 @@
+package unchained
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/alexandrevicenzi/unchained/argon2"
+	"github.com/alexandrevicenzi/unchained/md5"
+	"github.com/alexandrevicenzi/unchained/pbkdf2"
+	"github.com/alexandrevicenzi/unchained/sha1"
+)
+
+func newPBKDF2WrappedSHA1() *WrappedHasher {
+	h := pbkdf2.NewPBKDF2SHA256Hasher()
+	h.Iterations = 1000
+	return PBKDF2Wrapped(h, SHA1(sha1.NewSHA1PasswordHasher()))
+}
+
+func newArgon2WrappedUnsaltedMD5() *WrappedHasher {
+	h := argon2.NewArgon2Hasher()
+	h.Memory = 64
+	h.Threads = 1
+	return Argon2Wrapped(h, UnsaltedMD5(md5.NewUnsaltedMD5PasswordHasher()))
+}
+
+func TestWrappedEncode(t *testing.T) {
+	encoded, err := newPBKDF2WrappedSHA1().Encode("admin", "seasalt")
+
+	if err != nil {
+		t.Fatalf("Encode error: %s", err)
+	}
+
+	// pbkdf2_sha256(sha1("seasalt" + "admin"), "seasalt", 1000), as in Django.
+	expected := "pbkdf2_wrapped_sha1$1000$seasalt$unovmIUxiCMzo/5yW+YL2bmVdbG2RJnu2bu6xwIPf5M="
+
+	if encoded != expected {
+		t.Fatalf("Encoded hash %s does not match %s.", encoded, expected)
+	}
+}
+
+func TestWrappedWrap(t *testing.T) {
+	w := newPBKDF2WrappedSHA1()
+	encoded, err := w.Wrap("sha1$seasalt$b405ac2d4204af679dd4a3a9b2d9a8951e1cab75")
+
+	if err != nil {
+		t.Fatalf("Wrap error: %s", err)
+	}
+
+	if expected, _ := w.Encode("admin", "seasalt"); encoded != expected {
+		t.Fatalf("Wrapped hash %s does not match %s.", encoded, expected)
+	}
+
+	if valid, err := w.Verify("admin", encoded); !valid || err != nil {
+		t.Fatalf("Password should be valid, error: %v", err)
+	}
+
+	if valid, _ := w.Verify("wrong", encoded); valid {
+		t.Fatal("Password should not be valid.")
+	}
+
+	if _, err := w.Wrap("21232f297a57a5a743894a0e4a801fc3"); !errors.Is(err, ErrMalformedHash) {
+		t.Fatalf("Error %v is not %s.", err, ErrMalformedHash)
+	}
+}
+
+func TestWrappedWrapUnsalted(t *testing.T) {
+	w := newArgon2WrappedUnsaltedMD5()
+	encoded, err := w.Wrap("21232f297a57a5a743894a0e4a801fc3")
+
+	if err != nil {
+		t.Fatalf("Wrap error: %s", err)
+	}
+
+	if !strings.HasPrefix(encoded, "argon2_wrapped_unsalted_md5$argon2id$") {
+		t.Fatalf("Wrapped hash %s does not use the wrapped hasher.", encoded)
+	}
+
+	if valid, err := w.Verify("admin", encoded); !valid || err != nil {
+		t.Fatalf("Password should be valid, error: %v", err)
+	}
+
+	d, err := w.Decode(encoded)
+
+	if err != nil {
+		t.Fatalf("Decode error: %s", err)
+	}
+
+	if d.Algorithm != "argon2_wrapped_unsalted_md5" || len(d.Salt) != DefaultSaltSize {
+		t.Fatalf("Decoded hash %+v does not match.", d)
+	}
+}
+
+func TestWrapPassword(t *testing.T) {
+	wrappers := []*WrappedHasher{newPBKDF2WrappedSHA1(), newArgon2WrappedUnsaltedMD5()}
+
+	for encoded, algorithm := range map[string]string{
+		"sha1$seasalt$b405ac2d4204af679dd4a3a9b2d9a8951e1cab75": "pbkdf2_wrapped_sha1",
+		"21232f297a57a5a743894a0e4a801fc3":                      "argon2_wrapped_unsalted_md5",
+		"md5$$21232f297a57a5a743894a0e4a801fc3":                 "argon2_wrapped_unsalted_md5",
+	} {
+		wrapped, err := WrapPassword(encoded, wrappers...)
+
+		if err != nil {
+			t.Fatalf("WrapPassword error: %s", err)
+		}
+
+		if !strings.HasPrefix(wrapped, algorithm+"$") {
+			t.Fatalf("Wrapped hash %s does not use %s.", wrapped, algorithm)
+		}
+	}
+
+	if _, err := WrapPassword("md5$seasalt$f5531bef9f3687d0ccf0f617f0e25573", wrappers...); err != ErrInvalidHasher {
+		t.Fatalf("Error %v is not %s.", err, ErrInvalidHasher)
+	}
+}
+
+func TestWrappedUpgrade(t *testing.T) {
+	h := pbkdf2.NewPBKDF2SHA256Hasher()
+	h.Iterations = 1000
+	c, err := NewContext(PBKDF2(h), newPBKDF2WrappedSHA1())
+
+	if err != nil {
+		t.Fatalf("NewContext error: %s", err)
+	}
+
+	encoded, _ := newPBKDF2WrappedSHA1().Wrap("sha1$seasalt$b405ac2d4204af679dd4a3a9b2d9a8951e1cab75")
+
+	if !c.NeedsRehash(encoded) {
+		t.Fatal("Wrapped password should need rehash.")
+	}
+
+	var updated string
+
+	valid, err := c.CheckPasswordWithSetter("admin", encoded, func(encoded string) error {
+		updated = encoded
+		return nil
+	}, "default")
+
+	if !valid || err != nil {
+		t.Fatalf("Password should be valid, error: %v", err)
+	}
+
+	if c.IdentifyHasher(updated) != PBKDF2SHA256Hasher || c.NeedsRehash(updated) {
+		t.Fatalf("Password should be updated to %s: %s", PBKDF2SHA256Hasher, updated)
+	}
+}